@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultContextWindow = 8192
+	charsPerToken        = 4
+	promptReserveTokens  = 512
+)
+
+// pairSummaries joins summaries two by two.
+func pairSummaries(summaries []string) []string {
+	pairs := make([]string, 0, (len(summaries)+1)/2)
+	for i := 0; i < len(summaries); i += 2 {
+		pairs = append(pairs, strings.Join(summaries[i:min(i+2, len(summaries))], "\n\n---\n\n"))
+	}
+	return pairs
+}
+
+func (c *Config) chunkRunes() int {
+	window := c.ContextWindow
+	if window <= 0 {
+		window = defaultContextWindow
+	}
+
+	// Leave room for the instructions and for the model's answer.
+	tokens := window*3/4 - promptReserveTokens
+	if tokens < 256 {
+		tokens = 256
+	}
+	return tokens * charsPerToken
+}
+
+func splitIntoChunks(text string, size int) []string {
+	if utf8.RuneCountInString(text) <= size {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+
+	flush := func() {
+		if currentLen > 0 {
+			chunks = append(chunks, strings.TrimSpace(current.String()))
+			current.Reset()
+			currentLen = 0
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		lineLen := utf8.RuneCountInString(line)
+
+		for lineLen > size {
+			flush()
+			head, rest := splitRunesAtSpace(line, size)
+			chunks = append(chunks, strings.TrimSpace(head))
+			line = rest
+			lineLen = utf8.RuneCountInString(line)
+		}
+
+		if currentLen+lineLen+1 > size {
+			flush()
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		currentLen += lineLen + 1
+	}
+	flush()
+	return chunks
+}
+
+func splitRunesAtSpace(line string, size int) (string, string) {
+	runes := []rune(line)
+	cut := size
+	for i := size; i > size/2; i-- {
+		if runes[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return string(runes[:cut]), string(runes[cut:])
+}
@@ -5,16 +5,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Config struct {
-	Sources         []string `json:"sources"`
+	Sources         []Source `json:"sources"`
+	LLMProvider     string   `json:"llm_provider,omitempty"`
 	LLMAPIKey       string   `json:"llm_api_key"`
 	LLMAPIURL       string   `json:"llm_api_url"`
 	LLMAPIModel     string   `json:"llm_api_model"`
 	ScheduleMinutes int      `json:"schedule_minutes"`
 	SummaryPrompt   string   `json:"summary_prompt"`
 	FocusTopics     string   `json:"focus_topics"`
+	ContextWindow   int      `json:"context_window,omitempty"`
+	CacheTTLMinutes int      `json:"cache_ttl_minutes,omitempty"`
+	UserAgent       string   `json:"user_agent,omitempty"`
+
+	Schedule              string `json:"schedule,omitempty"`
+	QuietHours            string `json:"quiet_hours,omitempty"`
+	ScheduleJitterSeconds int    `json:"schedule_jitter_seconds,omitempty"`
+
+	LLMTimeoutSeconds int `json:"llm_timeout_seconds,omitempty"`
+	LLMMaxRetries     int `json:"llm_max_retries,omitempty"`
+
+	CrawlConcurrency int `json:"crawl_concurrency,omitempty"`
+	LLMConcurrency   int `json:"llm_concurrency,omitempty"`
+	HostDelaySeconds int `json:"host_delay_seconds,omitempty"`
+
+	ShutdownGraceSeconds int `json:"shutdown_grace_seconds,omitempty"`
+
+	StructuredSummaries bool `json:"structured_summaries,omitempty"`
+
+	Theme string `json:"theme,omitempty"`
 }
 
 func GetConfigPath() (string, error) {
@@ -41,9 +63,9 @@ func LoadConfig() (*Config, error) {
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		config := &Config{
-			Sources:         []string{},
+			Sources:         []Source{},
 			ScheduleMinutes: 15,
-			SummaryPrompt:   "Summarize the key news topics and main stories from this website. Focus on the most important headlines and provide a concise overview in markdown format.",
+			SummaryPrompt:   defaultSummaryPrompt,
 		}
 		if err := SaveConfig(config); err != nil {
 			return nil, err
@@ -62,7 +84,7 @@ func LoadConfig() (*Config, error) {
 	}
 
 	if config.SummaryPrompt == "" {
-		config.SummaryPrompt = "Summarize the key news topics and main stories from this website. Focus on the most important headlines and provide a concise overview in markdown format."
+		config.SummaryPrompt = defaultSummaryPrompt
 	}
 
 	return &config, nil
@@ -84,7 +106,7 @@ func SaveConfig(config *Config) error {
 		return err
 	}
 
-	if err := os.WriteFile(configPath, data, 0600); err != nil {
+	if err := writeFileAtomic(configPath, data, 0600); err != nil {
 		return err
 	}
 
@@ -92,13 +114,19 @@ func SaveConfig(config *Config) error {
 	return nil
 }
 
-func AddSource(config *Config, url string) error {
-	for _, source := range config.Sources {
-		if source == url {
-			return fmt.Errorf("source already exists: %s", url)
+func AddSource(config *Config, source Source) error {
+	if err := source.validate(); err != nil {
+		return err
+	}
+	for _, existing := range config.Sources {
+		if existing.URL == source.URL {
+			return fmt.Errorf("source already exists: %s", source.URL)
+		}
+		if source.Name != "" && existing.Name == source.Name {
+			return fmt.Errorf("source name already in use: %s", source.Name)
 		}
 	}
-	config.Sources = append(config.Sources, url)
+	config.Sources = append(config.Sources, source)
 	return SaveConfig(config)
 }
 
@@ -129,7 +157,7 @@ func RemoveSource(config *Config, idOrURL string) error {
 	
 	if idx == -1 {
 		for i, source := range config.Sources {
-			if source == idOrURL {
+			if source.URL == idOrURL || (source.Name != "" && source.Name == idOrURL) {
 				idx = i
 				break
 			}
@@ -152,6 +180,46 @@ func ListSources(config *Config) {
 	
 	fmt.Println("Sources:")
 	for i, source := range config.Sources {
-		fmt.Printf("  [%d] %s\n", i+1, source)
+		line := fmt.Sprintf("  [%d] %s", i+1, source.URL)
+		if source.Name != "" {
+			line = fmt.Sprintf("  [%d] %s - %s", i+1, source.Name, source.URL)
+		}
+		if len(source.Tags) > 0 {
+			line += fmt.Sprintf(" [%s]", strings.Join(source.Tags, ", "))
+		}
+		if !source.IsEnabled() {
+			line += " (disabled)"
+		}
+		fmt.Println(line)
+
+		var details []string
+		if source.Model != "" {
+			details = append(details, "model: "+source.Model)
+		}
+		if source.TTL != "" {
+			details = append(details, "ttl: "+source.TTL)
+		}
+		if source.Selector != "" {
+			details = append(details, "selector: "+source.Selector)
+		}
+		if source.Schedule != "" {
+			details = append(details, "schedule: "+source.Schedule)
+		}
+		if source.FollowLinks > 0 {
+			hosts := followSameHost
+			if source.FollowHosts != "" {
+				hosts = source.FollowHosts
+			}
+			details = append(details, fmt.Sprintf("follow: %d links (%s host)", source.FollowLinks, hosts))
+		}
+		if len(source.Headers) > 0 {
+			details = append(details, fmt.Sprintf("headers: %d", len(source.Headers)))
+		}
+		if source.Prompt != "" {
+			details = append(details, fmt.Sprintf("prompt: %q", source.Prompt))
+		}
+		if len(details) > 0 {
+			fmt.Printf("      %s\n", strings.Join(details, ", "))
+		}
 	}
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type controlRequest struct {
+	Command string `json:"command"`
+}
+
+type controlResponse struct {
+	OK      bool          `json:"ok"`
+	Message string        `json:"message,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Status  *DaemonStatus `json:"status,omitempty"`
+}
+
+type DaemonStatus struct {
+	PID          int             `json:"pid"`
+	StartedAt    time.Time       `json:"started_at"`
+	Running      bool            `json:"running"`
+	LastRunStart time.Time       `json:"last_run_started"`
+	LastRunEnd   time.Time       `json:"last_run_finished"`
+	LastRunError string          `json:"last_run_error,omitempty"`
+	NextRun      time.Time       `json:"next_run"`
+	Sources      []SourceOutcome `json:"sources"`
+
+	// ShutdownGraceSeconds is how long the daemon lets in-flight work
+	// run after SIGTERM: that of the current run, or of the config.
+	ShutdownGraceSeconds int `json:"shutdown_grace_seconds"`
+}
+
+type SourceOutcome struct {
+	URL     string    `json:"url"`
+	Name    string    `json:"name,omitempty"`
+	OK      bool      `json:"ok"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+	NextRun time.Time `json:"next_run"`
+}
+
+func GetSocketPath() (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "nub.sock"), nil
+}
+
+func (d *daemon) listenControl() (net.Listener, error) {
+	socketPath, err := GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// A socket left behind by a daemon that was killed would make
+	// Listen fail with "address already in use".
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func (d *daemon) serveControl(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handleControl(conn)
+	}
+}
+
+func (d *daemon) handleControl(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var req controlRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(controlResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	var resp controlResponse
+	switch req.Command {
+	case "status":
+		status := d.snapshot()
+		resp = controlResponse{OK: true, Status: &status}
+	case "trigger":
+		resp = controlResponse{OK: true, Message: d.requestRun()}
+		log.Printf("Run requested over control socket\n")
+	default:
+		resp = controlResponse{Error: fmt.Sprintf("unknown command: %s", req.Command)}
+	}
+
+	json.NewEncoder(conn).Encode(resp)
+}
+
+func sendControlCommand(command string) (*controlResponse, error) {
+	if running, _ := IsDaemonRunning(); !running {
+		return nil, fmt.Errorf("daemon is not running")
+	}
+
+	socketPath, err := GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("unix", socketPath, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(controlRequest{Command: command}); err != nil {
+		return nil, err
+	}
+
+	var resp controlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid response from daemon: %v", err)
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return &resp, nil
+}
+
+func ShowDaemonStatus() error {
+	resp, err := sendControlCommand("status")
+	if err != nil {
+		return err
+	}
+	status := resp.Status
+	if status == nil {
+		return fmt.Errorf("daemon returned no status")
+	}
+
+	now := time.Now()
+	fmt.Printf("Daemon running (PID: %d)\n", status.PID)
+	fmt.Printf("Uptime:   %s\n", now.Sub(status.StartedAt).Round(time.Second))
+
+	if status.Running {
+		fmt.Printf("Last run: in progress since %s\n", status.LastRunStart.Format(time.RFC3339))
+	} else if !status.LastRunEnd.IsZero() {
+		took := status.LastRunEnd.Sub(status.LastRunStart).Round(time.Second)
+		fmt.Printf("Last run: %s (took %s)\n", status.LastRunEnd.Format(time.RFC3339), took)
+	} else {
+		fmt.Println("Last run: never")
+	}
+	if status.LastRunError != "" {
+		fmt.Printf("          error: %s\n", status.LastRunError)
+	}
+
+	if !status.NextRun.IsZero() {
+		fmt.Printf("Next run: %s (in %s)\n", status.NextRun.Format(time.RFC3339), status.NextRun.Sub(now).Round(time.Second))
+	}
+
+	if len(status.Sources) > 0 {
+		fmt.Println()
+		fmt.Println("Sources:")
+		for _, source := range status.Sources {
+			name := source.URL
+			if source.Name != "" {
+				name = source.Name + " - " + source.URL
+			}
+			switch {
+			case source.At.IsZero():
+				fmt.Printf("  - %s (not run yet)\n", name)
+			case source.OK:
+				fmt.Printf("  ✓ %s (%s)\n", name, source.At.Format(time.RFC3339))
+			default:
+				fmt.Printf("  ✗ %s (%s): %s\n", name, source.At.Format(time.RFC3339), source.Error)
+			}
+			if !source.NextRun.IsZero() {
+				fmt.Printf("      next: %s\n", source.NextRun.Format(time.RFC3339))
+			}
+		}
+	}
+	return nil
+}
+
+func TriggerDaemonRun() error {
+	resp, err := sendControlCommand("trigger")
+	if err != nil {
+		return err
+	}
+	fmt.Println(resp.Message)
+	return nil
+}
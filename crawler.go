@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,39 +16,147 @@ import (
 	"golang.org/x/net/html/charset"
 )
 
-func CrawlWebsite(url string) (string, error) {
+const defaultCacheTTL = 24 * time.Hour
+
+type CacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+type CrawlResult struct {
+	Content      string
+	ETag         string
+	LastModified string
+	NotModified  bool
+}
+
+func (c *Config) cacheTTL() time.Duration {
+	if c.CacheTTLMinutes <= 0 {
+		return defaultCacheTTL
+	}
+	return time.Duration(c.CacheTTLMinutes) * time.Minute
+}
+
+func fetchSource(ctx context.Context, config *Config, limiter *crawlLimiter, src Source, logf func(string, ...any)) (string, error) {
+	source := src.URL
+	cached, err := IsCached(source, src.cacheTTL(config))
+	if err != nil {
+		return "", err
+	}
+
+	if cached {
+		logf("  Using cached content for %s\n", source)
+		return GetCachedContent(source)
+	}
+
+	logf("  Crawling %s\n", source)
+	meta, err := GetCacheMeta(source)
+	if err != nil {
+		return "", err
+	}
+
+	if err := limiter.allowed(ctx, source); err != nil {
+		return "", err
+	}
+
+	release, err := limiter.acquire(ctx, source)
+	if err != nil {
+		return "", err
+	}
+	result, err := CrawlWebsite(ctx, source, config.userAgent(), src.Headers, meta)
+	release()
+	if err != nil {
+		return "", err
+	}
+
+	var content string
+	if result.NotModified {
+		logf("  Not modified, reusing cached content for %s\n", source)
+		content, err = GetCachedContent(source)
+		if err != nil {
+			return "", err
+		}
+		if err := TouchCache(source); err != nil {
+			return "", err
+		}
+	} else {
+		content = result.Content
+		if err := CacheContent(source, content); err != nil {
+			return "", err
+		}
+	}
+
+	if err := StoreCacheMeta(source, result); err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+func CrawlWebsite(ctx context.Context, url, userAgent string, headers map[string]string, meta *CacheMeta) (*CrawlResult, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if meta != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && meta != nil {
+		result := &CrawlResult{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			NotModified:  true,
+		}
+		if result.ETag == "" {
+			result.ETag = meta.ETag
+		}
+		if result.LastModified == "" {
+			result.LastModified = meta.LastModified
+		}
+		return result, nil
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
 	reader, err := charset.NewReader(resp.Body, contentType)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	body, err := io.ReadAll(reader)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(body), nil
+	return &CrawlResult{
+		Content:      string(body),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
 }
 
 func getCacheFilePath(url string) (string, error) {
@@ -65,7 +175,15 @@ func getCacheFilePath(url string) (string, error) {
 	return filepath.Join(cacheDir, filename), nil
 }
 
-func IsCached(url string) (bool, error) {
+func getCacheMetaPath(url string) (string, error) {
+	cachePath, err := getCacheFilePath(url)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cachePath, ".html") + ".json", nil
+}
+
+func IsCached(url string, ttl time.Duration) (bool, error) {
 	cachePath, err := getCacheFilePath(url)
 	if err != nil {
 		return false, err
@@ -80,7 +198,7 @@ func IsCached(url string) (bool, error) {
 	}
 
 	age := time.Since(info.ModTime())
-	if age > 24*time.Hour {
+	if age > ttl {
 		return false, nil
 	}
 
@@ -107,7 +225,66 @@ func CacheContent(url, content string) error {
 		return err
 	}
 
-	return os.WriteFile(cachePath, []byte(content), 0644)
+	return writeFileAtomic(cachePath, []byte(content), 0644)
+}
+
+func TouchCache(url string) error {
+	cachePath, err := getCacheFilePath(url)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return os.Chtimes(cachePath, now, now)
+}
+
+func GetCacheMeta(url string) (*CacheMeta, error) {
+	cachePath, err := getCacheFilePath(url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	metaPath, err := getCacheMetaPath(url)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var meta CacheMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, nil
+	}
+	return &meta, nil
+}
+
+func StoreCacheMeta(url string, result *CrawlResult) error {
+	metaPath, err := getCacheMetaPath(url)
+	if err != nil {
+		return err
+	}
+
+	meta := CacheMeta{
+		URL:          url,
+		ETag:         result.ETag,
+		LastModified: result.LastModified,
+		FetchedAt:    time.Now(),
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, data, 0644)
 }
 
 func ClearCache() error {
@@ -129,61 +306,3 @@ func ClearCache() error {
 
 	return os.MkdirAll(cacheDir, 0755)
 }
-
-func extractTextFromHTML(html string) string {
-	text := html
-	text = strings.ReplaceAll(text, "<script", "\n<script")
-	text = strings.ReplaceAll(text, "</script>", "</script>\n")
-	text = strings.ReplaceAll(text, "<style", "\n<style")
-	text = strings.ReplaceAll(text, "</style>", "</style>\n")
-	
-	inScript := false
-	inStyle := false
-	result := strings.Builder{}
-	
-	for _, line := range strings.Split(text, "\n") {
-		if strings.Contains(line, "<script") {
-			inScript = true
-			continue
-		}
-		if strings.Contains(line, "</script>") {
-			inScript = false
-			continue
-		}
-		if strings.Contains(line, "<style") {
-			inStyle = true
-			continue
-		}
-		if strings.Contains(line, "</style>") {
-			inStyle = false
-			continue
-		}
-		
-		if !inScript && !inStyle {
-			result.WriteString(line)
-			result.WriteString("\n")
-		}
-	}
-	
-	text = result.String()
-	
-	for strings.Contains(text, "<") && strings.Contains(text, ">") {
-		start := strings.Index(text, "<")
-		end := strings.Index(text[start:], ">")
-		if end == -1 {
-			break
-		}
-		text = text[:start] + " " + text[start+end+1:]
-	}
-	
-	lines := strings.Split(text, "\n")
-	cleanLines := make([]string, 0)
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && len(line) > 3 {
-			cleanLines = append(cleanLines, line)
-		}
-	}
-	
-	return strings.Join(cleanLines, "\n")
-}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -68,7 +71,8 @@ func StartDaemon(config *Config) error {
 		return fmt.Errorf("failed to start daemon: %v", err)
 	}
 
-	if err := os.WriteFile(pidPath, []byte(fmt.Sprintf("%d", process.Pid)), 0644); err != nil {
+	pid := process.Pid
+	if err := os.WriteFile(pidPath, []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
 		logFile.Close()
 		return fmt.Errorf("failed to write PID file: %v", err)
 	}
@@ -76,9 +80,9 @@ func StartDaemon(config *Config) error {
 	logFile.Close()
 	process.Release()
 
-	fmt.Printf("Daemon started successfully (PID: %d)\n", process.Pid)
+	fmt.Printf("Daemon started successfully (PID: %d)\n", pid)
 	fmt.Printf("Logs: %s\n", logPath)
-	fmt.Printf("Schedule: every %d minutes\n", config.ScheduleMinutes)
+	fmt.Printf("Schedule: %s\n", config.scheduleSpec())
 
 	return nil
 }
@@ -114,12 +118,22 @@ func IsDaemonRunning() (bool, int) {
 	return true, pid
 }
 
-func StopDaemon() error {
+// StopDaemon asks the daemon to shut down and waits for it to exit. The
+// daemon finishes in-flight work within its shutdown grace period; if it is
+// still alive well after that, it is killed. The grace period is asked from
+// the daemon, as its config may differ from ours; config is the fallback
+// when the daemon cannot be reached.
+func StopDaemon(config *Config) error {
 	isRunning, pid := IsDaemonRunning()
 	if !isRunning {
 		return fmt.Errorf("daemon is not running")
 	}
 
+	grace := config.shutdownGrace()
+	if resp, err := sendControlCommand("status"); err == nil && resp.Status != nil && resp.Status.ShutdownGraceSeconds > 0 {
+		grace = time.Duration(resp.Status.ShutdownGraceSeconds) * time.Second
+	}
+
 	process, err := os.FindProcess(pid)
 	if err != nil {
 		return fmt.Errorf("failed to find process: %v", err)
@@ -129,14 +143,34 @@ func StopDaemon() error {
 		return fmt.Errorf("failed to stop daemon: %v", err)
 	}
 
+	fmt.Printf("Waiting for daemon to finish (PID: %d)...\n", pid)
+	deadline := time.Now().Add(grace + 10*time.Second)
+	for process.Signal(syscall.Signal(0)) == nil {
+		if time.Now().After(deadline) {
+			fmt.Println("Daemon did not exit in time, killing it")
+			process.Signal(syscall.SIGKILL)
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
 	pidPath, _ := GetPidPath()
 	os.Remove(pidPath)
+	if socketPath, err := GetSocketPath(); err == nil {
+		os.Remove(socketPath)
+	}
 
 	fmt.Printf("Daemon stopped (PID: %d)\n", pid)
 	return nil
 }
 
 func RunDaemonChild(config *Config) {
+	// SIGHUP reloads the config. Catch it before anything else, as its
+	// default action would kill the daemon.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
 	logPath, err := GetLogPath()
 	if err != nil {
 		log.Fatal(err)
@@ -151,100 +185,250 @@ func RunDaemonChild(config *Config) {
 	log.SetOutput(logFile)
 	log.SetFlags(log.LstdFlags)
 
-	log.Printf("Daemon started, crawling every %d minutes\n", config.ScheduleMinutes)
+	log.Printf("Daemon started, schedule %s\n", config.scheduleSpec())
 
-	runDaemonLoop(config)
-}
+	// The first SIGTERM or SIGINT starts a graceful shutdown; once it has
+	// been seen the handler is removed so a second one kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	context.AfterFunc(ctx, stop)
 
-func runDaemonLoop(config *Config) {
-	ticker := time.NewTicker(time.Duration(config.ScheduleMinutes) * time.Minute)
-	defer ticker.Stop()
+	d := newDaemon(config)
 
-	if err := runOnceLogged(config); err != nil {
-		log.Printf("Error in initial run: %v\n", err)
+	listener, err := d.listenControl()
+	if err != nil {
+		log.Printf("Warning: control socket unavailable: %v\n", err)
+	} else {
+		defer listener.Close()
+		go d.serveControl(listener)
 	}
 
-	for range ticker.C {
-		log.Printf("Starting scheduled crawl...\n")
-		if err := runOnceLogged(config); err != nil {
-			log.Printf("Error: %v\n", err)
-		}
+	go d.watchConfig(ctx, hup)
+	d.runLoop(ctx)
+
+	log.Printf("Daemon stopped\n")
+	if pidPath, err := GetPidPath(); err == nil {
+		os.Remove(pidPath)
+	}
+	if socketPath, err := GetSocketPath(); err == nil {
+		os.Remove(socketPath)
 	}
 }
 
-func runOnceLogged(config *Config) error {
-	if err := validateConfig(config); err != nil {
-		return err
+// scheduleCheckInterval is how often the daemon looks for due sources. Due
+// times are compared by wall clock, so runs missed while the machine was
+// asleep are caught up on the first check after it wakes.
+const scheduleCheckInterval = 30 * time.Second
+
+type daemon struct {
+	mu       sync.Mutex
+	config   *Config
+	status   DaemonStatus
+	outcomes map[string]SourceOutcome
+	lastRuns map[string]time.Time
+	due      map[string]time.Time
+	badSpecs map[string]bool
+	trigger  chan struct{}
+	reloaded chan struct{}
+}
+
+func newDaemon(config *Config) *daemon {
+	lastRuns, err := GetLastRuns()
+	if err != nil {
+		log.Printf("Warning: failed to load schedule state: %v\n", err)
+	}
+	return &daemon{
+		config:   config,
+		status:   DaemonStatus{PID: os.Getpid(), StartedAt: time.Now()},
+		outcomes: make(map[string]SourceOutcome),
+		lastRuns: lastRuns,
+		due:      make(map[string]time.Time),
+		badSpecs: make(map[string]bool),
+		trigger:  make(chan struct{}, 1),
+		reloaded: make(chan struct{}, 1),
 	}
+}
 
-	log.Println("Starting crawl and summarization...")
-	var allSummaries []string
-	for _, source := range config.Sources {
-		summary, err := processSourceLogged(config, source)
-		if err != nil {
-			log.Printf("Error processing %s: %v\n", source, err)
-			continue
-		}
-		if config.FocusTopics != "" && summary != "" {
-			allSummaries = append(allSummaries, summary)
+func (d *daemon) runLoop(ctx context.Context) {
+	d.reschedule()
+
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	lastCheck := time.Now().Round(0)
+	for {
+		if due := d.dueSources(); len(due) > 0 {
+			log.Printf("Starting scheduled crawl of %d sources...\n", len(due))
+			if err := d.run(ctx, due); err != nil {
+				log.Printf("Error: %v\n", err)
+			}
+			lastCheck = time.Now().Round(0)
 		}
-	}
 
-	if config.FocusTopics != "" && len(allSummaries) > 0 {
-		log.Println("Extracting focused content from all summaries...")
-		combinedSummaries := strings.Join(allSummaries, "\n\n---\n\n")
-		focused, err := ExtractFocusedContent(config, combinedSummaries)
-		if err != nil {
-			log.Printf("Warning: failed to extract focused content: %v\n", err)
-		} else if focused != "" && focused != "No relevant content found." {
-			if err := StoreCombinedFocusedContent(focused); err != nil {
-				log.Printf("Warning: failed to store focused content: %v\n", err)
+		select {
+		case <-ctx.Done():
+			log.Printf("Shutdown requested\n")
+			return
+		case <-ticker.C:
+			now := time.Now().Round(0)
+			if gap := now.Sub(lastCheck); gap > 2*scheduleCheckInterval {
+				log.Printf("Clock jumped %s since the last check (suspended?), catching up\n", gap.Round(time.Second))
+			}
+			lastCheck = now
+		case <-d.trigger:
+			log.Printf("Starting triggered crawl...\n")
+			d.mu.Lock()
+			sources := d.config.enabledSources()
+			d.mu.Unlock()
+			if err := d.run(ctx, sources); err != nil {
+				log.Printf("Error: %v\n", err)
 			}
+			lastCheck = time.Now().Round(0)
+		case <-d.reloaded:
+			d.reschedule()
 		}
 	}
+}
 
-	log.Println("Done!")
-	return nil
+// reschedule works out the next run of every enabled source from when it
+// last ran.
+func (d *daemon) reschedule() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().Round(0)
+	due := make(map[string]time.Time)
+	for _, source := range d.config.enabledSources() {
+		due[source.URL] = d.nextRun(source, d.lastRuns[source.URL], now)
+	}
+	d.due = due
+}
+
+// nextRun works out when source runs next. An invalid schedule is logged
+// the first time it is seen. The caller must hold d.mu.
+func (d *daemon) nextRun(source Source, last, now time.Time) time.Time {
+	due, err := nextRun(d.config, source, last, now)
+	if spec := source.scheduleSpec(d.config); err != nil && !d.badSpecs[spec] {
+		d.badSpecs[spec] = true
+		log.Printf("Warning: invalid schedule %q for %s, running every %dm: %v\n", spec, source.URL, defaultScheduleMinutes, err)
+	}
+	return due
 }
 
-func processSourceLogged(config *Config, source string) (string, error) {
-	log.Printf("Processing: %s\n", source)
+// dueSources returns the enabled sources whose next run has come. Nothing
+// is due during quiet hours; runs missed then happen when they end.
+func (d *daemon) dueSources() []Source {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	cached, err := IsCached(source)
+	now := time.Now().Round(0)
+	quiet, _ := parseQuietHours(d.config.QuietHours)
+	if quiet.contains(now) {
+		return nil
+	}
+
+	var due []Source
+	for _, source := range d.config.enabledSources() {
+		if next, ok := d.due[source.URL]; ok && !next.After(now) {
+			due = append(due, source)
+		}
+	}
+	return due
+}
+
+func (d *daemon) run(ctx context.Context, sources []Source) error {
+	d.mu.Lock()
+	config := d.config
+	d.status.Running = true
+	d.status.LastRunStart = time.Now()
+	started := d.status.LastRunStart.Round(0)
+	d.status.ShutdownGraceSeconds = int(config.shutdownGrace().Seconds())
+	d.mu.Unlock()
+
+	results, err := runBatch(ctx, config, sources, false, daemonLog)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.Running = false
+	d.status.LastRunEnd = time.Now()
+	d.status.LastRunError = ""
 	if err != nil {
-		return "", err
+		d.status.LastRunError = err.Error()
 	}
 
-	var content string
-	if cached {
-		log.Printf("  Using cached content for %s\n", source)
-		content, err = GetCachedContent(source)
-		if err != nil {
-			return "", err
+	now := time.Now().Round(0)
+	if results == nil {
+		// The batch failed before any source ran, e.g. on an invalid
+		// config. Wait for the next scheduled run rather than retrying
+		// on every check.
+		for _, source := range sources {
+			d.due[source.URL] = d.nextRun(source, started, now)
 		}
-	} else {
-		log.Printf("  Crawling %s\n", source)
-		content, err = CrawlWebsite(source)
-		if err != nil {
-			return "", err
+		return err
+	}
+	for i, result := range results {
+		if errors.Is(result.Err, errSkipped) {
+			continue
 		}
-		if err := CacheContent(source, content); err != nil {
-			return "", err
+		outcome := SourceOutcome{URL: result.Source, OK: result.Err == nil, At: d.status.LastRunEnd}
+		if result.Err != nil {
+			outcome.Error = result.Err.Error()
 		}
+		d.outcomes[result.Source] = outcome
+		d.lastRuns[result.Source] = started
+		d.due[result.Source] = d.nextRun(sources[i], started, now)
 	}
-
-	log.Printf("  Summarizing %s\n", source)
-	summary, err := SummarizeWithAI(config, content, source)
-	if err != nil {
-		return "", err
+	if err := StoreLastRuns(d.lastRuns); err != nil {
+		log.Printf("Warning: failed to store schedule state: %v\n", err)
 	}
+	return err
+}
 
-	if err := StoreSummarization(source, summary); err != nil {
-		return "", err
+// requestRun queues a run; while one is in progress at most one more is
+// queued behind it.
+func (d *daemon) requestRun() string {
+	d.mu.Lock()
+	running := d.status.Running
+	d.mu.Unlock()
+
+	select {
+	case d.trigger <- struct{}{}:
+		if running {
+			return "Run in progress, another run queued"
+		}
+		return "Run started"
+	default:
+		return "Run already queued"
 	}
+}
 
-	log.Printf("  ✓ Completed %s\n", source)
-	return summary, nil
+func (d *daemon) snapshot() DaemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := d.status
+	if !status.Running {
+		status.ShutdownGraceSeconds = int(d.config.shutdownGrace().Seconds())
+	}
+	status.Sources = nil
+	for _, source := range d.config.Sources {
+		outcome, ok := d.outcomes[source.URL]
+		if !ok {
+			outcome = SourceOutcome{URL: source.URL}
+		}
+		outcome.Name = source.Name
+		if next, ok := d.due[source.URL]; ok && source.IsEnabled() {
+			outcome.NextRun = next
+			if status.NextRun.IsZero() || next.Before(status.NextRun) {
+				status.NextRun = next
+			}
+		}
+		if !source.IsEnabled() && !ok {
+			outcome.Error = "disabled"
+		}
+		status.Sources = append(status.Sources, outcome)
+	}
+	return status
 }
 
 func ShowLogs() error {
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxFeedEntries = 50
+	atomFeedFile   = "digest.atom"
+	rssFeedFile    = "digest.rss"
+)
+
+// feedEntry is a stored summary or combined focus summary published in
+// the digest feeds.
+type feedEntry struct {
+	ID        string
+	Title     string
+	Link      string
+	Generated time.Time
+	HTML      string
+}
+
+// feedEntries returns the most recent stored summaries of all sources,
+// newest first, with the combined focus summary if there is one.
+func feedEntries(config *Config) ([]feedEntry, error) {
+	summariesDir, err := getSummariesDir()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := os.ReadDir(summariesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive []StoredSummary
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		entries, err := listArchive(filepath.Join(summariesDir, dir.Name()))
+		if err != nil {
+			continue
+		}
+		archive = append(archive, entries...)
+	}
+	sort.Slice(archive, func(i, j int) bool {
+		return archive[i].Generated.After(archive[j].Generated)
+	})
+	if len(archive) > maxFeedEntries {
+		archive = archive[:maxFeedEntries]
+	}
+
+	var entries []feedEntry
+	if config.FocusTopics != "" {
+		if entry, ok := focusFeedEntry(config); ok {
+			entries = append(entries, entry)
+		}
+	}
+	for _, stored := range archive {
+		summary, err := readStoredSummary(stored.Path)
+		if err != nil {
+			continue
+		}
+		id := filepath.Base(filepath.Dir(summary.Path))
+		name := strings.TrimSuffix(filepath.Base(summary.Path), ".md")
+		entries = append(entries, feedEntry{
+			ID:        feedID("summary/" + id + "/" + name),
+			Title:     sourceTitle(config, summary.URL) + " - " + summary.Generated.Local().Format("2006-01-02 15:04"),
+			Link:      summary.URL,
+			Generated: summary.Generated,
+			HTML:      markdownToHTML(stripSummaryHeader(summary.Content)),
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Generated.After(entries[j].Generated)
+	})
+	return entries, nil
+}
+
+func focusFeedEntry(config *Config) (feedEntry, bool) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return feedEntry{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, "focus", "combined.md"))
+	if err != nil {
+		return feedEntry{}, false
+	}
+	content := string(data)
+	generated := parseGeneratedTime(content)
+	if generated.IsZero() {
+		return feedEntry{}, false
+	}
+
+	return feedEntry{
+		ID:        feedID("focus/" + generated.UTC().Format(time.RFC3339)),
+		Title:     "Focus: " + config.FocusTopics + " - " + generated.Local().Format("2006-01-02 15:04"),
+		Generated: generated,
+		HTML:      markdownToHTML(stripSummaryHeader(content)),
+	}, true
+}
+
+// feedID derives a stable urn:uuid from name, so an entry keeps its ID
+// however often the feed is regenerated.
+func feedID(name string) string {
+	sum := md5.Sum([]byte("nub:" + name))
+	sum[6] = sum[6]&0x0f | 0x30
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+type digestAtomFeed struct {
+	XMLName xml.Name          `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string            `xml:"id"`
+	Title   string            `xml:"title"`
+	Updated string            `xml:"updated"`
+	Author  digestAtomAuthor  `xml:"author"`
+	Links   []digestAtomLink  `xml:"link"`
+	Entries []digestAtomEntry `xml:"entry"`
+}
+
+type digestAtomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type digestAtomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type digestAtomEntry struct {
+	ID      string            `xml:"id"`
+	Title   string            `xml:"title"`
+	Updated string            `xml:"updated"`
+	Links   []digestAtomLink  `xml:"link"`
+	Content digestAtomContent `xml:"content"`
+}
+
+type digestAtomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// atomFeedXML renders entries as an Atom feed. selfURL is the feed's own
+// address, or "" when it is not known.
+func atomFeedXML(entries []feedEntry, selfURL string) ([]byte, error) {
+	feed := digestAtomFeed{
+		ID:     feedID("feed"),
+		Title:  "nub digest",
+		Author: digestAtomAuthor{Name: "nub"},
+	}
+	if selfURL != "" {
+		feed.Links = append(feed.Links, digestAtomLink{Rel: "self", Type: "application/atom+xml", Href: selfURL})
+	}
+
+	updated := time.Unix(0, 0)
+	for _, entry := range entries {
+		if entry.Generated.After(updated) {
+			updated = entry.Generated
+		}
+		atom := digestAtomEntry{
+			ID:      entry.ID,
+			Title:   entry.Title,
+			Updated: entry.Generated.UTC().Format(time.RFC3339),
+			Content: digestAtomContent{Type: "html", Body: entry.HTML},
+		}
+		if entry.Link != "" {
+			atom.Links = append(atom.Links, digestAtomLink{Rel: "alternate", Href: entry.Link})
+		}
+		feed.Entries = append(feed.Entries, atom)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	return marshalFeed(feed)
+}
+
+type digestRSSFeed struct {
+	XMLName xml.Name         `xml:"rss"`
+	Version string           `xml:"version,attr"`
+	Channel digestRSSChannel `xml:"channel"`
+}
+
+type digestRSSChannel struct {
+	Title         string          `xml:"title"`
+	Link          string          `xml:"link"`
+	Description   string          `xml:"description"`
+	LastBuildDate string          `xml:"lastBuildDate,omitempty"`
+	Items         []digestRSSItem `xml:"item"`
+}
+
+type digestRSSItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	GUID        digestRSSGUID `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Description string        `xml:"description"`
+}
+
+type digestRSSGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// rssFeedXML renders entries as an RSS 2.0 feed. siteURL is the channel
+// link, which RSS requires.
+func rssFeedXML(entries []feedEntry, siteURL string) ([]byte, error) {
+	feed := digestRSSFeed{
+		Version: "2.0",
+		Channel: digestRSSChannel{
+			Title:       "nub digest",
+			Link:        siteURL,
+			Description: "Summaries of the websites followed by nub",
+		},
+	}
+	for i, entry := range entries {
+		if i == 0 {
+			feed.Channel.LastBuildDate = entry.Generated.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, digestRSSItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        digestRSSGUID{ID: entry.ID},
+			PubDate:     entry.Generated.Format(time.RFC1123Z),
+			Description: entry.HTML,
+		})
+	}
+	return marshalFeed(feed)
+}
+
+func marshalFeed(feed any) ([]byte, error) {
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// WriteFeeds writes the digest as digest.atom and digest.rss to the data
+// directory. The RSS channel link, which RSS requires, is the file itself.
+func WriteFeeds(config *Config) error {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return err
+	}
+	entries, err := feedEntries(config)
+	if err != nil {
+		return err
+	}
+
+	atom, err := atomFeedXML(entries, "")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dataDir, atomFeedFile), atom, 0644); err != nil {
+		return err
+	}
+
+	rssPath := filepath.Join(dataDir, rssFeedFile)
+	rss, err := rssFeedXML(entries, (&url.URL{Scheme: "file", Path: filepath.ToSlash(rssPath)}).String())
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(rssPath, rss, 0644)
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const minMainContentLength = 200
+
+var boilerplateTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Canvas:   true,
+	atom.Nav:      true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Input:    true,
+	atom.Textarea: true,
+	atom.Dialog:   true,
+	atom.Head:     true,
+}
+
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"dialog":        true,
+	"alertdialog":   true,
+	"menu":          true,
+	"menubar":       true,
+	"search":        true,
+}
+
+var (
+	boilerplatePattern = regexp.MustCompile(`(?i)cookie|consent|gdpr|banner|newsletter|subscribe|signup|share|social|sidebar|footer|navbar|\bnav\b|menu|breadcrumb|popup|modal|overlay|advert|\bads?\b|sponsor|promo|related|skip-link|masthead`)
+	contentPattern     = regexp.MustCompile(`(?i)article|content|main|post|entry|story|blog|text`)
+)
+
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Main: true, atom.Header: true, atom.Ul: true, atom.Ol: true,
+	atom.Li: true, atom.Table: true, atom.Tr: true, atom.Blockquote: true,
+	atom.Pre: true, atom.Hr: true, atom.Br: true, atom.Dl: true,
+	atom.Dt: true, atom.Dd: true, atom.Figure: true, atom.Figcaption: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Tbody: true, atom.Thead: true,
+}
+
+var paragraphTags = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Td: true, atom.Blockquote: true,
+	atom.Li: true, atom.Dd: true,
+}
+
+// extractContent returns the text to summarize. Links in HTML pages are
+// kept as markdown links, resolved against pageURL.
+func extractContent(content, pageURL, selector string) string {
+	if feed, ok := parseFeed(content); ok {
+		return feedToText(feed)
+	}
+	if selector != "" {
+		if text := extractSelectedText(content, pageURL, selector); text != "" {
+			return text
+		}
+	}
+	return extractTextFromHTML(content, pageURL)
+}
+
+func extractSelectedText(content, pageURL, value string) string {
+	sel, err := parseSelector(value)
+	if err != nil {
+		return ""
+	}
+
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+
+	base := documentBase(doc, pageURL)
+	nodes := sel.selectNodes(doc)
+	for _, n := range nodes {
+		removeBoilerplate(n, true)
+	}
+	return renderText(nodes, base)
+}
+
+// extractTextFromHTML returns the main content of a page as text. With an
+// empty pageURL links are dropped and only their text is kept.
+func extractTextFromHTML(content, pageURL string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+
+	base := documentBase(doc, pageURL)
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+
+	removeBoilerplate(body, false)
+
+	text := ""
+	if selected := findMainContent(body); len(selected) > 0 {
+		text = renderText(selected, base)
+	}
+	if utf8.RuneCountInString(text) < minMainContentLength {
+		text = renderText([]*html.Node{body}, base)
+	}
+
+	return text
+}
+
+// documentBase returns the URL links in doc are relative to: the page URL,
+// or the page's <base href> if it has one.
+func documentBase(doc *html.Node, pageURL string) *url.URL {
+	if pageURL == "" {
+		return nil
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	if n := findElement(doc, atom.Base); n != nil {
+		if href, err := url.Parse(strings.TrimSpace(getAttr(n, "href"))); err == nil {
+			base = base.ResolveReference(href)
+		}
+	}
+	return base
+}
+
+// resolveLink returns href as an absolute http(s) URL, or "" for anchors,
+// scripts and other links the model can't cite.
+func resolveLink(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if base == nil || href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	abs := base.ResolveReference(ref)
+	if abs.Scheme != "http" && abs.Scheme != "https" {
+		return ""
+	}
+	abs.Fragment = ""
+	return abs.String()
+}
+
+func hasBlockContent(n *html.Node) bool {
+	for d := range n.Descendants() {
+		if d.Type == html.ElementNode && blockTags[d.DataAtom] && d.DataAtom != atom.Br {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	linkTextEscaper = strings.NewReplacer("[", `\[`, "]", `\]`)
+	linkURLEscaper  = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20")
+)
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func removeBoilerplate(n *html.Node, inArticle bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode {
+			if isBoilerplate(c, inArticle) {
+				n.RemoveChild(c)
+			} else {
+				removeBoilerplate(c, inArticle || c.DataAtom == atom.Article || c.DataAtom == atom.Main)
+			}
+		}
+		c = next
+	}
+}
+
+func isBoilerplate(n *html.Node, inArticle bool) bool {
+	if boilerplateTags[n.DataAtom] {
+		return true
+	}
+	if n.DataAtom == atom.Header && !inArticle {
+		return true
+	}
+	if boilerplateRoles[strings.ToLower(getAttr(n, "role"))] {
+		return true
+	}
+	if hasAttr(n, "hidden") || getAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ToLower(strings.ReplaceAll(getAttr(n, "style"), " ", ""))
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+		return true
+	}
+
+	names := getAttr(n, "class") + " " + getAttr(n, "id")
+	return boilerplatePattern.MatchString(names) && !contentPattern.MatchString(names)
+}
+
+func classWeight(n *html.Node) float64 {
+	names := getAttr(n, "class") + " " + getAttr(n, "id")
+	weight := 0.0
+	if contentPattern.MatchString(names) {
+		weight += 25
+	}
+	if boilerplatePattern.MatchString(names) {
+		weight -= 25
+	}
+	return weight
+}
+
+func tagWeight(n *html.Node) float64 {
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		return 10
+	case atom.Div:
+		return 5
+	case atom.Section, atom.Pre, atom.Td, atom.Blockquote:
+		return 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Form:
+		return -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		return -5
+	}
+	return 0
+}
+
+func isParagraphLike(n *html.Node) bool {
+	if paragraphTags[n.DataAtom] {
+		return true
+	}
+	if n.DataAtom != atom.Div {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.DataAtom] && c.DataAtom != atom.Br {
+			return false
+		}
+	}
+	return true
+}
+
+func findMainContent(body *html.Node) []*html.Node {
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = tagWeight(n) + classWeight(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	for n := range body.Descendants() {
+		if n.Type != html.ElementNode || !isParagraphLike(n) {
+			continue
+		}
+		text := strings.TrimSpace(textContent(n))
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			continue
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(length)/100, 3)
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+		}
+	}
+
+	var top *html.Node
+	topScore := 0.0
+	for _, n := range candidates {
+		scores[n] *= 1 - linkDensity(n)
+		if top == nil || scores[n] > topScore {
+			top = n
+			topScore = scores[n]
+		}
+	}
+	if top == nil {
+		return nil
+	}
+
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+
+	threshold := max(10, topScore*0.2)
+	var selected []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+		if sibling == top {
+			selected = append(selected, sibling)
+			continue
+		}
+		if score, ok := scores[sibling]; ok && score >= threshold {
+			selected = append(selected, sibling)
+			continue
+		}
+		if sibling.DataAtom == atom.P {
+			text := textContent(sibling)
+			if utf8.RuneCountInString(text) > 80 && linkDensity(sibling) < 0.25 {
+				selected = append(selected, sibling)
+			}
+		}
+	}
+
+	return selected
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	for d := range n.Descendants() {
+		if d.Type == html.TextNode {
+			b.WriteString(d.Data)
+		}
+	}
+	return b.String()
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(strings.TrimSpace(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	for d := range n.Descendants() {
+		if d.Type == html.ElementNode && d.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(strings.TrimSpace(textContent(d)))
+		}
+	}
+	return float64(linked) / float64(total)
+}
+
+func renderText(nodes []*html.Node, base *url.URL) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		renderNode(&b, n, base)
+		b.WriteString("\n")
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if isMeaningfulLine(line) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderNode(b *strings.Builder, n *html.Node, base *url.URL) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+
+	if n.DataAtom == atom.A {
+		if link := resolveLink(base, getAttr(n, "href")); link != "" {
+			renderLink(b, n, base, link)
+			return
+		}
+	}
+
+	block := blockTags[n.DataAtom]
+	if block {
+		b.WriteString("\n")
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		b.WriteString(strings.Repeat("#", level) + " ")
+	case atom.Li:
+		b.WriteString("- ")
+	case atom.Td, atom.Th:
+		b.WriteString(" ")
+	case atom.Img:
+		if alt := strings.TrimSpace(getAttr(n, "alt")); alt != "" {
+			b.WriteString(" " + alt + " ")
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		renderNode(b, c, base)
+	}
+
+	if block {
+		b.WriteString("\n")
+	}
+}
+
+// renderLink writes an anchor as a markdown link. An anchor wrapping block
+// content, such as a linked heading, is rendered as is with the link
+// appended to its last line.
+func renderLink(b *strings.Builder, n *html.Node, base *url.URL, link string) {
+	var inner strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		renderNode(&inner, c, base)
+	}
+
+	link = linkURLEscaper.Replace(link)
+	text := strings.TrimSpace(inner.String())
+	if text == "" {
+		return
+	}
+	if !hasBlockContent(n) {
+		fmt.Fprintf(b, "[%s](%s)", linkTextEscaper.Replace(strings.Join(strings.Fields(text), " ")), link)
+		return
+	}
+	fmt.Fprintf(b, "\n%s [link](%s)\n", text, link)
+}
+
+func isMeaningfulLine(line string) bool {
+	line = strings.TrimLeft(line, "#- ")
+	if utf8.RuneCountInString(line) < 2 {
+		return false
+	}
+	for _, r := range line {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
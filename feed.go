@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const maxFeedItems = 50
+const maxFeedDescription = 1000
+
+type Feed struct {
+	Title string
+	Link  string
+	Items []FeedItem
+}
+
+type FeedItem struct {
+	Title       string
+	Link        string
+	Published   string
+	Description string
+}
+
+type rssFeed struct {
+	Channel rssChannel `xml:"channel"`
+}
+
+type rdfFeed struct {
+	Channel rssChannel `xml:"channel"`
+	Items   []rssItem  `xml:"item"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title     atomText   `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   atomText   `xml:"summary"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+func newFeedDecoder(content string) *xml.Decoder {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	// CrawlWebsite has already converted the body to UTF-8, so the
+	// encoding named in the XML declaration no longer applies.
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+func detectFeedRoot(content string) string {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+	if !strings.HasPrefix(trimmed, "<") {
+		return ""
+	}
+
+	decoder := newFeedDecoder(trimmed)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "rss", "feed", "RDF":
+				return start.Name.Local
+			}
+			return ""
+		}
+	}
+}
+
+func parseFeed(content string) (*Feed, bool) {
+	root := detectFeedRoot(content)
+	if root == "" {
+		return nil, false
+	}
+
+	content = strings.TrimSpace(strings.TrimPrefix(content, "\ufeff"))
+	feed := &Feed{}
+
+	switch root {
+	case "rss":
+		var rss rssFeed
+		if err := newFeedDecoder(content).Decode(&rss); err != nil {
+			return nil, false
+		}
+		feed.Title = strings.TrimSpace(rss.Channel.Title)
+		feed.Link = strings.TrimSpace(rss.Channel.Link)
+		for _, item := range rss.Channel.Items {
+			feed.Items = append(feed.Items, item.toFeedItem())
+		}
+	case "RDF":
+		var rdf rdfFeed
+		if err := newFeedDecoder(content).Decode(&rdf); err != nil {
+			return nil, false
+		}
+		feed.Title = strings.TrimSpace(rdf.Channel.Title)
+		feed.Link = strings.TrimSpace(rdf.Channel.Link)
+		items := rdf.Items
+		if len(items) == 0 {
+			items = rdf.Channel.Items
+		}
+		for _, item := range items {
+			feed.Items = append(feed.Items, item.toFeedItem())
+		}
+	case "feed":
+		var atom atomFeed
+		if err := newFeedDecoder(content).Decode(&atom); err != nil {
+			return nil, false
+		}
+		feed.Title = strings.TrimSpace(atom.Title)
+		feed.Link = pickAtomLink(atom.Links)
+		for _, entry := range atom.Entries {
+			feed.Items = append(feed.Items, entry.toFeedItem())
+		}
+	}
+
+	return feed, true
+}
+
+func (item rssItem) toFeedItem() FeedItem {
+	link := strings.TrimSpace(item.Link)
+	if link == "" && strings.HasPrefix(strings.TrimSpace(item.GUID), "http") {
+		link = strings.TrimSpace(item.GUID)
+	}
+
+	published := item.PubDate
+	if published == "" {
+		published = item.Date
+	}
+
+	description := item.Description
+	if strings.TrimSpace(description) == "" {
+		description = item.Content
+	}
+
+	return FeedItem{
+		Title:       strings.TrimSpace(item.Title),
+		Link:        link,
+		Published:   normalizeFeedDate(published),
+		Description: feedDescriptionText(description),
+	}
+}
+
+func (entry atomEntry) toFeedItem() FeedItem {
+	published := entry.Published
+	if published == "" {
+		published = entry.Updated
+	}
+
+	description := entry.Summary.String()
+	if description == "" {
+		description = entry.Content.String()
+	}
+
+	return FeedItem{
+		Title:       entry.Title.String(),
+		Link:        pickAtomLink(entry.Links),
+		Published:   normalizeFeedDate(published),
+		Description: feedDescriptionText(description),
+	}
+}
+
+func pickAtomLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	if len(links) > 0 {
+		return strings.TrimSpace(links[0].Href)
+	}
+	return ""
+}
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func normalizeFeedDate(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format("2006-01-02 15:04 MST")
+		}
+	}
+	return value
+}
+
+func feedDescriptionText(description string) string {
+	text := strings.TrimSpace(description)
+	if strings.Contains(text, "<") {
+		text = extractTextFromHTML(text, "")
+	}
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if len(runes) > maxFeedDescription {
+		text = string(runes[:maxFeedDescription]) + "..."
+	}
+	return text
+}
+
+func feedToText(feed *Feed) string {
+	var b strings.Builder
+
+	if feed.Title != "" {
+		fmt.Fprintf(&b, "Feed: %s\n", feed.Title)
+	}
+	if feed.Link != "" {
+		fmt.Fprintf(&b, "Link: %s\n", feed.Link)
+	}
+
+	for i, item := range feed.Items {
+		if i >= maxFeedItems {
+			break
+		}
+		b.WriteString("\n")
+		if item.Title != "" {
+			fmt.Fprintf(&b, "Title: %s\n", item.Title)
+		}
+		if item.Link != "" {
+			fmt.Fprintf(&b, "Link: %s\n", item.Link)
+		}
+		if item.Published != "" {
+			fmt.Fprintf(&b, "Published: %s\n", item.Published)
+		}
+		if item.Description != "" {
+			fmt.Fprintf(&b, "Description: %s\n", item.Description)
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxFollowLinks     = 30
+	maxArticleRunes    = 6000
+	minArticleLinkText = 15
+)
+
+// Follow scopes for Source.FollowHosts.
+const (
+	followSameHost = "same"
+	followAnyHost  = "any"
+)
+
+// articleLink is a link picked from an index page, with the text it was
+// linked under.
+type articleLink struct {
+	URL   string
+	Title string
+}
+
+// skippedLinkExts are file types that are never articles.
+var skippedLinkExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".svg": true,
+	".webp": true, ".zip": true, ".gz": true, ".tar": true, ".mp3": true,
+	".mp4": true, ".exe": true, ".dmg": true,
+}
+
+// articleLinks picks up to n article links from an index page, in page
+// order. For feeds these are the item links; for HTML pages they are the
+// links outside navigation and other boilerplate whose text is long enough
+// to be a headline, which leaves out "comments", "login" and the like.
+func articleLinks(content, pageURL string, n int, anyHost bool) []articleLink {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []articleLink
+	if feed, ok := parseFeed(content); ok {
+		for _, item := range feed.Items {
+			candidates = append(candidates, articleLink{URL: resolveLink(base, item.Link), Title: item.Title})
+		}
+	} else {
+		doc, err := html.Parse(strings.NewReader(content))
+		if err != nil {
+			return nil
+		}
+		base = documentBase(doc, pageURL)
+		body := findElement(doc, atom.Body)
+		if body == nil {
+			body = doc
+		}
+		removeBoilerplate(body, false)
+
+		for a := range body.Descendants() {
+			if a.Type != html.ElementNode || a.DataAtom != atom.A {
+				continue
+			}
+			title := strings.Join(strings.Fields(textContent(a)), " ")
+			if utf8.RuneCountInString(title) < minArticleLinkText {
+				continue
+			}
+			candidates = append(candidates, articleLink{URL: resolveLink(base, getAttr(a, "href")), Title: title})
+		}
+	}
+
+	seen := map[string]bool{base.String(): true}
+	var links []articleLink
+	for _, link := range candidates {
+		if len(links) == n {
+			break
+		}
+		if link.URL == "" || seen[link.URL] {
+			continue
+		}
+		seen[link.URL] = true
+
+		u, err := url.Parse(link.URL)
+		if err != nil || skippedLinkExts[strings.ToLower(path.Ext(u.Path))] {
+			continue
+		}
+		if !anyHost && !sameHost(u, base) {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+func (l articleLink) heading() string {
+	title := l.Title
+	if title == "" {
+		title = l.URL
+	}
+	return fmt.Sprintf("## [%s](%s)", linkTextEscaper.Replace(title), linkURLEscaper.Replace(l.URL))
+}
+
+func sameHost(a, b *url.URL) bool {
+	return strings.TrimPrefix(strings.ToLower(a.Hostname()), "www.") ==
+		strings.TrimPrefix(strings.ToLower(b.Hostname()), "www.")
+}
+
+// followArticles fetches the articles linked from an index page and
+// returns their text for summarizing. Articles are cached like any page.
+// One that cannot be fetched is represented by its headline only, so a
+// single dead link does not fail the source.
+func followArticles(ctx context.Context, config *Config, limiter *crawlLimiter, src Source, index string, logs runLog) (string, error) {
+	links := articleLinks(index, src.URL, src.FollowLinks, src.FollowHosts == followAnyHost)
+	if len(links) == 0 {
+		logs.logf("  No article links found on %s, summarizing the page itself\n", src.URL)
+		return extractContent(index, src.URL, src.Selector), nil
+	}
+	logs.logf("  Following %d article links from %s\n", len(links), src.URL)
+
+	base, _ := url.Parse(src.URL)
+	texts := make([]string, len(links))
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			article := Source{URL: link.URL}
+			// Custom headers may carry credentials meant for the source only.
+			if u, err := url.Parse(link.URL); err == nil && sameHost(u, base) {
+				article.Headers = src.Headers
+			}
+
+			content, err := fetchSource(ctx, config, limiter, article, logs.logf)
+			if err != nil {
+				logs.warnf("  Warning: failed to fetch article %s: %v\n", link.URL, err)
+				texts[i] = link.heading() + "\n\n(The article could not be fetched.)"
+				return
+			}
+
+			text := extractContent(content, link.URL, "")
+			if runes := []rune(text); len(runes) > maxArticleRunes {
+				text = string(runes[:maxArticleRunes]) + "..."
+			}
+			texts[i] = link.heading() + "\n\n" + text
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	header := fmt.Sprintf("The following are the top %d articles linked from %s, each under a heading that links to it.", len(links), src.URL)
+	return header + "\n\n" + strings.Join(texts, "\n\n---\n\n"), nil
+}
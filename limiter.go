@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crawlLimiter bounds the number of concurrent fetches and keeps at
+// most one request in flight per host, spaced at least delay apart, or
+// further if the host's robots.txt asks for a longer Crawl-delay. It lives
+// for one run, so the spacing holds across all sources of the run.
+type crawlLimiter struct {
+	slots     chan struct{}
+	delay     time.Duration
+	userAgent string
+
+	mu     sync.Mutex
+	hosts  map[string]*hostSlot
+	robots map[string]*robotsEntry
+}
+
+type hostSlot struct {
+	mu         sync.Mutex
+	last       time.Time
+	crawlDelay time.Duration
+}
+
+func newCrawlLimiter(concurrency int, delay time.Duration, userAgent string) *crawlLimiter {
+	return &crawlLimiter{
+		slots:     make(chan struct{}, concurrency),
+		delay:     delay,
+		userAgent: userAgent,
+		hosts:     make(map[string]*hostSlot),
+		robots:    make(map[string]*robotsEntry),
+	}
+}
+
+func (l *crawlLimiter) acquire(ctx context.Context, rawURL string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	slot := l.hostSlot(hostKey(rawURL))
+	slot.mu.Lock()
+	if wait := time.Until(slot.last.Add(l.hostDelay(slot))); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			slot.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		slot.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	return func() {
+		<-l.slots
+		slot.last = time.Now()
+		slot.mu.Unlock()
+	}, nil
+}
+
+func (l *crawlLimiter) hostSlot(host string) *hostSlot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slot, ok := l.hosts[host]
+	if !ok {
+		slot = &hostSlot{}
+		l.hosts[host] = slot
+	}
+	return slot
+}
+
+func (l *crawlLimiter) hostDelay(slot *hostSlot) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return max(l.delay, slot.crawlDelay)
+}
+
+func (l *crawlLimiter) setCrawlDelay(host string, delay time.Duration) {
+	slot := l.hostSlot(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slot.crawlDelay = delay
+}
+
+func hostKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(u.Host)
+}
@@ -1,165 +1,141 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
 )
 
-type ChatCompletionRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
-}
+const defaultSummaryPrompt = "Summarize the key news topics and main stories from this website. Focus on the most important headlines and provide a concise overview in markdown format."
 
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
+const markdownFormat = "Format your response in clean markdown with headings, bullet points, and clear structure."
 
-type ChatCompletionResponse struct {
-	Choices []Choice `json:"choices"`
-}
+const citeLinks = "Links in the content are written as markdown links. When you mention a story that has a link, cite it as a markdown link to that exact URL. Never make up URLs."
 
-type Choice struct {
-	Message Message `json:"message"`
+func SummarizeWithAI(ctx context.Context, config *Config, provider LLMProvider, text, url string) (string, error) {
+	return summarize(ctx, config, provider, text, url, markdownFormat)
 }
 
-func SummarizeWithAI(config *Config, htmlContent, url string) (string, error) {
-	text := extractTextFromHTML(htmlContent)
-	
-	if len(text) > 8000 {
-		text = text[:8000]
-	}
-
+// summarize runs the summary prompt over text, splitting it into chunks
+// when it does not fit the context window. format is the instruction on
+// the shape of the final answer.
+func summarize(ctx context.Context, config *Config, provider LLMProvider, text, url, format string) (string, error) {
 	userPrompt := config.SummaryPrompt
 	if userPrompt == "" {
-		userPrompt = "Summarize the key news topics and main stories from this website. Focus on the most important headlines and provide a concise overview in markdown format."
+		userPrompt = defaultSummaryPrompt
 	}
 
-	prompt := fmt.Sprintf(`%s
+	chunks := splitIntoChunks(text, config.chunkRunes())
+	if len(chunks) <= 1 {
+		prompt := fmt.Sprintf(`%s
 
 Website URL: %s
 
 Content:
 %s
 
-Format your response in clean markdown with headings, bullet points, and clear structure.`, userPrompt, url, text)
-
-	reqBody := ChatCompletionRequest{
-		Model: config.LLMAPIModel,
-		Messages: []Message{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream: false,
-	}
+%s
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
+%s`, userPrompt, url, text, citeLinks, format)
+		return provider.Complete(ctx, prompt)
 	}
 
-	req, err := http.NewRequest("POST", config.LLMAPIURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
+	partials := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		partial, err := summarizeChunk(ctx, provider, chunk, url, i+1, len(chunks))
+		if err != nil {
+			return "", fmt.Errorf("chunk %d/%d: %v", i+1, len(chunks), err)
+		}
+		partials = append(partials, partial)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+config.LLMAPIKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+	return reduceSummaries(ctx, config, provider, userPrompt, url, format, partials)
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+func contentHash(config *Config, text string) string {
+	hash := sha256.New()
+	parts := []string{config.providerName(), config.LLMAPIModel, config.SummaryPrompt, text}
+	if config.StructuredSummaries {
+		parts = append(parts, "structured")
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
 	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
 
-	var chatResp ChatCompletionResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return "", err
-	}
+func summarizeChunk(ctx context.Context, provider LLMProvider, chunk, url string, part, total int) (string, error) {
+	prompt := fmt.Sprintf(`The following is part %d of %d of the content of a website. Summarize the stories, facts and points in this part as concise markdown bullet points. Keep names, numbers and dates, and keep the markdown link of each story you mention. Do not add an introduction or conclusion.
 
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from LLM")
-	}
+Website URL: %s
 
-	summary := strings.TrimSpace(chatResp.Choices[0].Message.Content)
-	return summary, nil
+Content:
+%s`, part, total, url, chunk)
+	return provider.Complete(ctx, prompt)
 }
 
-func ExtractFocusedContent(config *Config, summary string) (string, error) {
-	if config.FocusTopics == "" {
-		return "", nil
-	}
+// mergeSummaries condenses a group of partial summaries into one, for
+// reduceSummaries when they are too long to be merged in a single call.
+func mergeSummaries(ctx context.Context, provider LLMProvider, group, url string) (string, error) {
+	prompt := fmt.Sprintf(`The following are summaries of consecutive parts of a website, separated by ---. Merge them into one list of concise markdown bullet points, removing duplicates. Keep names, numbers and dates, and keep the markdown link of each story you mention. Do not add an introduction or conclusion.
 
-	prompt := fmt.Sprintf(`Extract only the content related to these topics: %s
-
-From this summary, extract and list ONLY the items that are directly related to the specified topics. Return them as a bullet list in markdown format. If there are no relevant items, return "No relevant content found."
+Website URL: %s
 
-Summary:
-%s`, config.FocusTopics, summary)
+Partial summaries:
+%s`, url, group)
+	return provider.Complete(ctx, prompt)
+}
 
-	reqBody := ChatCompletionRequest{
-		Model: config.LLMAPIModel,
-		Messages: []Message{
-			{Role: "user", Content: prompt},
-		},
-		Stream: false,
+func reduceSummaries(ctx context.Context, config *Config, provider LLMProvider, userPrompt, url, format string, partials []string) (string, error) {
+	combined := strings.Join(partials, "\n\n---\n\n")
+	groups := splitIntoChunks(combined, config.chunkRunes())
+
+	if len(groups) > 1 && len(partials) > 1 {
+		// When the partials are too long to be grouped, merge them in
+		// pairs so that every level at least halves their number.
+		if len(groups) >= len(partials) {
+			groups = pairSummaries(partials)
+		}
+		merged := make([]string, 0, len(groups))
+		for i, group := range groups {
+			partial, err := mergeSummaries(ctx, provider, group, url)
+			if err != nil {
+				return "", fmt.Errorf("reduce %d/%d: %v", i+1, len(groups), err)
+			}
+			merged = append(merged, partial)
+		}
+		return reduceSummaries(ctx, config, provider, userPrompt, url, format, merged)
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
-	}
+	prompt := fmt.Sprintf(`%s
 
-	req, err := http.NewRequest("POST", config.LLMAPIURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
+Website URL: %s
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+config.LLMAPIKey)
+The website was too long to read at once, so it was split into consecutive parts and each part was summarized. Merge the partial summaries below into a single summary of the whole website, removing duplicates.
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+Partial summaries:
+%s
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+%s
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("LLM API error: %s - %s", resp.Status, string(body))
-	}
+%s`, userPrompt, url, combined, citeLinks, format)
+	return provider.Complete(ctx, prompt)
+}
 
-	var chatResp ChatCompletionResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return "", err
+func ExtractFocusedContent(ctx context.Context, config *Config, provider LLMProvider, summary string) (string, error) {
+	if config.FocusTopics == "" {
+		return "", nil
 	}
 
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from LLM")
-	}
+	prompt := fmt.Sprintf(`Extract only the content related to these topics: %s
+
+From this summary, extract and list ONLY the items that are directly related to the specified topics. Return them as a bullet list in markdown format. If there are no relevant items, return "No relevant content found."
+
+Summary:
+%s`, config.FocusTopics, summary)
 
-	focused := strings.TrimSpace(chatResp.Choices[0].Message.Content)
-	return focused, nil
+	return provider.Complete(ctx, prompt)
 }
@@ -1,32 +1,62 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
-	"time"
+	"syscall"
 )
 
 func main() {
 	helpFlag := flag.Bool("help", false, "Show help")
 	
 	runMode := flag.Bool("run", false, "Run crawl and summarization once")
+	forceRun := flag.Bool("force", false, "Summarize again even if content is unchanged")
 	daemonMode := flag.Bool("d", false, "Run in daemon mode")
 	stopDaemon := flag.Bool("stop", false, "Stop running daemon")
+	statusMode := flag.Bool("status", false, "Show daemon status")
+	triggerRun := flag.Bool("trigger", false, "Ask the daemon to run now")
 	showMode := flag.Bool("show", false, "Show summarizations in pager")
 	showHTML := flag.Bool("show-html", false, "Show summarizations in HTML browser")
+	showSince := flag.String("since", "", "Show all summaries generated since a date (YYYY-MM-DD)")
+	showDate := flag.String("date", "", "Show summaries as they were on a date (YYYY-MM-DD)")
+	serveAddr := flag.String("serve", "", "Serve summaries over HTTP on this address (e.g. :8080)")
+	outputFormat := flag.String("format", "", "With --show or --list: output format (json, ndjson, markdown, text)")
+	sortOrder := flag.String("sort", "", "With --show or --show-html: order of sources (config, updated, name, tag)")
+	searchQuery := flag.String("search", "", "Search stored summaries")
+	searchSource := flag.String("source", "", "With --search: only this source (ID, name, URL or tag)")
 	
 	listSources := flag.Bool("list", false, "List all sources")
 	addSource := flag.String("add-source", "", "Add a source URL")
-	remSource := flag.String("rem-source", "", "Remove a source by ID or URL")
+	remSource := flag.String("rem-source", "", "Remove a source by ID, name or URL")
+	sourceName := flag.String("name", "", "With --add-source: display name")
+	sourceTags := flag.String("tags", "", "With --add-source: comma-separated tags")
+	sourcePrompt := flag.String("prompt", "", "With --add-source: summarization prompt for this source")
+	sourceModel := flag.String("model", "", "With --add-source: LLM model for this source")
+	sourceTTL := flag.String("ttl", "", "With --add-source: cache TTL for this source (e.g. 30m, 6h)")
+	sourceSelector := flag.String("selector", "", "With --add-source: CSS selector of the content to summarize")
+	sourceSchedule := flag.String("schedule", "", "With --add-source: cron schedule for this source")
+	sourceFollow := flag.Int("follow", 0, "With --add-source: summarize the top N linked articles instead of the page")
+	sourceFollowHosts := flag.String("follow-hosts", "", "With --add-source: follow links to the same host (\"same\", default) or \"any\" host")
+	sourceDisabled := flag.Bool("disabled", false, "With --add-source: add the source disabled")
+	var sourceHeaders headerFlags
+	flag.Var(&sourceHeaders, "header", "With --add-source: extra request header \"Name: value\" (repeatable)")
 	
+	setLLMProvider := flag.String("set-llm-provider", "", "Set LLM provider (openai, anthropic, ollama, gemini)")
 	setLLMAPIKey := flag.String("set-llm-api-key", "", "Set LLM API key")
-	setLLMAPIURL := flag.String("set-llm-api-url", "", "Set LLM API URL")
+	setLLMAPIURL := flag.String("set-llm-api-url", "", "Set LLM API URL (\"off\" to use the provider's default)")
 	setLLMAPIModel := flag.String("set-llm-api-model", "", "Set LLM API model")
 	setScheduleTime := flag.Int("set-schedule-time", 0, "Set schedule time in minutes")
+	setSchedule := flag.String("set-schedule", "", "Set cron schedule for daemon mode (\"off\" to use --set-schedule-time)")
+	setQuietHours := flag.String("set-quiet-hours", "", "Set quiet hours as HH:MM-HH:MM (\"off\" to disable)")
 	setPrompt := flag.String("set-prompt", "", "Set custom summarization prompt")
 	setFocus := flag.String("set-focus", "", "Set focus topics (comma-separated)")
+	setContextWindow := flag.Int("set-context-window", 0, "Set LLM context window in tokens")
+	setTheme := flag.String("set-theme", "", "Set HTML theme (auto, light, dark, high-contrast)")
+	setStructured := flag.String("set-structured-summaries", "", "Ask for structured JSON summaries (on, off)")
 	
 	logsMode := flag.Bool("logs", false, "View logs in pager")
 	clearCache := flag.Bool("clear-cache", false, "Clear cached websites")
@@ -55,6 +85,30 @@ func main() {
 		return
 	}
 
+	if *setLLMProvider != "" {
+		previous := config.providerName()
+		config.LLMProvider = strings.ToLower(*setLLMProvider)
+		if _, err := NewLLMProvider(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// An API URL belongs to one provider's API.
+		clearedURL := ""
+		if config.providerName() != previous && config.LLMAPIURL != "" {
+			clearedURL = config.LLMAPIURL
+			config.LLMAPIURL = ""
+		}
+		if err := SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("LLM provider set to: %s\n", config.LLMProvider)
+		if clearedURL != "" {
+			fmt.Printf("LLM API URL %s cleared, set one with --set-llm-api-url if needed\n", clearedURL)
+		}
+		return
+	}
+
 	if *setLLMAPIKey != "" {
 		config.LLMAPIKey = *setLLMAPIKey
 		if err := SaveConfig(config); err != nil {
@@ -66,12 +120,20 @@ func main() {
 	}
 
 	if *setLLMAPIURL != "" {
-		config.LLMAPIURL = *setLLMAPIURL
+		if *setLLMAPIURL == "off" {
+			config.LLMAPIURL = ""
+		} else {
+			config.LLMAPIURL = *setLLMAPIURL
+		}
 		if err := SaveConfig(config); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println("LLM API URL set successfully")
+		if config.LLMAPIURL == "" {
+			fmt.Println("LLM API URL cleared, using the provider's default")
+		} else {
+			fmt.Println("LLM API URL set successfully")
+		}
 		return
 	}
 
@@ -95,6 +157,46 @@ func main() {
 		return
 	}
 
+	if *setSchedule != "" {
+		if *setSchedule == "off" {
+			config.Schedule = ""
+		} else {
+			if _, err := parseSchedule(*setSchedule); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			config.Schedule = *setSchedule
+		}
+		if err := SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Schedule set to: %s\n", config.scheduleSpec())
+		return
+	}
+
+	if *setQuietHours != "" {
+		if *setQuietHours == "off" {
+			config.QuietHours = ""
+		} else {
+			if _, err := parseQuietHours(*setQuietHours); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			config.QuietHours = *setQuietHours
+		}
+		if err := SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		if config.QuietHours == "" {
+			fmt.Println("Quiet hours disabled")
+		} else {
+			fmt.Printf("Quiet hours set to: %s\n", config.QuietHours)
+		}
+		return
+	}
+
 	if *setPrompt != "" {
 		config.SummaryPrompt = *setPrompt
 		if err := SaveConfig(config); err != nil {
@@ -115,8 +217,82 @@ func main() {
 		return
 	}
 
+	if *setContextWindow > 0 {
+		config.ContextWindow = *setContextWindow
+		if err := SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Context window set to %d tokens\n", *setContextWindow)
+		return
+	}
+
+	if *setTheme != "" {
+		if err := validateTheme(*setTheme); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		config.Theme = *setTheme
+		if err := SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Theme set to: %s\n", *setTheme)
+		return
+	}
+
+	if *setStructured != "" {
+		switch strings.ToLower(*setStructured) {
+		case "on":
+			config.StructuredSummaries = true
+		case "off":
+			config.StructuredSummaries = false
+		default:
+			fmt.Fprintf(os.Stderr, "Error: invalid value %q, use on or off\n", *setStructured)
+			os.Exit(1)
+		}
+		if err := SaveConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			os.Exit(1)
+		}
+		if config.StructuredSummaries {
+			fmt.Println("Structured summaries enabled")
+		} else {
+			fmt.Println("Structured summaries disabled")
+		}
+		return
+	}
+
 	if *addSource != "" {
-		if err := AddSource(config, *addSource); err != nil {
+		source := Source{
+			URL:      *addSource,
+			Name:     *sourceName,
+			Tags:     parseTags(*sourceTags),
+			Prompt:   *sourcePrompt,
+			Model:    *sourceModel,
+			TTL:      *sourceTTL,
+			Selector: *sourceSelector,
+			Schedule: *sourceSchedule,
+
+			FollowLinks: *sourceFollow,
+			FollowHosts: *sourceFollowHosts,
+		}
+		if *sourceDisabled {
+			enabled := false
+			source.Enabled = &enabled
+		}
+		for _, header := range sourceHeaders {
+			key, value, err := parseHeader(header)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error adding source: %v\n", err)
+				os.Exit(1)
+			}
+			if source.Headers == nil {
+				source.Headers = make(map[string]string)
+			}
+			source.Headers[key] = value
+		}
+		if err := AddSource(config, source); err != nil {
 			fmt.Fprintf(os.Stderr, "Error adding source: %v\n", err)
 			os.Exit(1)
 		}
@@ -133,8 +309,21 @@ func main() {
 		return
 	}
 
+	format, err := parseOutputFormat(*outputFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *outputFormat != "" && (!*showMode && !*listSources || *searchQuery != "") {
+		fmt.Fprintf(os.Stderr, "Error: --format only works with --show and --list\n")
+		os.Exit(1)
+	}
+
 	if *listSources {
-		ListSources(config)
+		if err := ListSourcesFormat(config, format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing sources: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
@@ -176,23 +365,88 @@ func main() {
 	}
 
 	if *stopDaemon {
-		if err := StopDaemon(); err != nil {
+		if err := StopDaemon(config); err != nil {
 			fmt.Fprintf(os.Stderr, "Error stopping daemon: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
+	if *statusMode {
+		if err := ShowDaemonStatus(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *triggerRun {
+		if err := TriggerDaemonRun(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *runMode {
-		if err := runOnce(config); err != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		context.AfterFunc(ctx, stop)
+
+		if _, err := runOnce(ctx, config, *forceRun, consoleLog); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var filter SummaryFilter
+	if *showSince != "" {
+		if filter.Since, err = ParseSummaryDate(*showSince); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *showDate != "" {
+		date, err := ParseSummaryDate(*showDate)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		filter.AsOf = date.AddDate(0, 0, 1)
+	}
+	order, err := parseSortOrder(*sortOrder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *searchQuery != "" {
+		searchFilter := SearchFilter{Since: filter.Since}
+		if !filter.AsOf.IsZero() {
+			searchFilter.Since = filter.AsOf.AddDate(0, 0, -1)
+			searchFilter.Until = filter.AsOf
+		}
+		if *searchSource != "" {
+			if searchFilter.SourceIDs, err = searchSourceIDs(config, *searchSource); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		search := SearchSummaries
+		if *showHTML {
+			search = SearchSummariesHTML
+		}
+		if err := search(*searchQuery, searchFilter); err != nil {
+			fmt.Fprintf(os.Stderr, "Error searching summarizations: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
 	if *showMode {
-		if err := ShowSummarizations(); err != nil {
+		if err := ShowSummarizations(filter, order, format); err != nil {
 			fmt.Fprintf(os.Stderr, "Error showing summarizations: %v\n", err)
 			os.Exit(1)
 		}
@@ -200,13 +454,21 @@ func main() {
 	}
 
 	if *showHTML {
-		if err := ShowSummarizationsHTML(); err != nil {
+		if err := ShowSummarizationsHTML(filter, order); err != nil {
 			fmt.Fprintf(os.Stderr, "Error showing summarizations: %v\n", err)
 			os.Exit(1)
 		}
 		return
 	}
 
+	if *serveAddr != "" {
+		if err := Serve(*serveAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error serving summarizations: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if flag.NFlag() == 0 {
 		showHelp()
 		return
@@ -218,23 +480,58 @@ func showHelp() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  nub --run                        Run crawl and summarization once")
+	fmt.Println("  nub --run --force                Summarize again even if content is unchanged")
 	fmt.Println("  nub -d                           Run in daemon mode")
 	fmt.Println("  nub --stop                       Stop running daemon")
+	fmt.Println("  nub --status                     Show daemon status")
+	fmt.Println("  nub --trigger                    Ask the daemon to run now")
 	fmt.Println("  nub --show                       Show summarizations in pager")
 	fmt.Println("  nub --show-html                  Show summarizations in HTML browser")
+	fmt.Println("  nub --serve <addr>               Serve summarizations over HTTP (e.g. :8080)")
+	fmt.Println("  nub --show --date <YYYY-MM-DD>   Show summaries as they were on a date")
+	fmt.Println("  nub --show --since <YYYY-MM-DD>  Show every summary generated since a date")
+	fmt.Println("  nub --show --format <format>     Output as json, ndjson, markdown or text")
+	fmt.Println("  nub --show --sort <order>        Order sources by config, updated, name or tag")
+	fmt.Println("  nub --search <query>             Search all stored summaries")
+	fmt.Println()
+	fmt.Println("  Options for --search:")
+	fmt.Println("    --source <id|name|url|tag>     Only search this source")
+	fmt.Println("    --since <YYYY-MM-DD>           Only summaries generated since a date")
+	fmt.Println("    --date <YYYY-MM-DD>            Only summaries generated on a date")
+	fmt.Println("    --show-html                    Show the results in the browser")
 	fmt.Println()
 	fmt.Println("Source Management:")
 	fmt.Println("  nub --list                       List all sources")
+	fmt.Println("  nub --list --format <format>     List sources as json, ndjson, markdown or text")
 	fmt.Println("  nub --add-source <url>           Add a source URL")
-	fmt.Println("  nub --rem-source <id or url>     Remove a source by ID or URL")
+	fmt.Println("  nub --rem-source <id|name|url>   Remove a source by ID, name or URL")
+	fmt.Println()
+	fmt.Println("  Options for --add-source:")
+	fmt.Println("    --name <name>                  Display name")
+	fmt.Println("    --tags <a,b>                   Comma-separated tags")
+	fmt.Println("    --prompt <text>                Summarization prompt for this source")
+	fmt.Println("    --model <model>                LLM model for this source")
+	fmt.Println("    --ttl <duration>               Cache TTL for this source (e.g. 30m, 6h)")
+	fmt.Println("    --selector <css>               CSS selector of the content to summarize")
+	fmt.Println("    --schedule <cron>              Daemon schedule for this source (e.g. \"0 9 * * *\")")
+	fmt.Println("    --follow <n>                   Summarize the top N linked articles instead of the page")
+	fmt.Println("    --follow-hosts <same|any>      Follow links to the same host only (default) or any host")
+	fmt.Println("    --header \"Name: value\"         Extra request header (repeatable)")
+	fmt.Println("    --disabled                     Add the source disabled")
 	fmt.Println()
 	fmt.Println("Configuration:")
+	fmt.Println("  nub --set-llm-provider <name>    Set LLM provider (openai, anthropic, ollama, gemini)")
 	fmt.Println("  nub --set-llm-api-key <key>      Set LLM API key")
-	fmt.Println("  nub --set-llm-api-url <url>      Set LLM API URL")
+	fmt.Println("  nub --set-llm-api-url <url>      Set LLM API URL (\"off\" for the default)")
 	fmt.Println("  nub --set-llm-api-model <model>  Set LLM API model")
 	fmt.Println("  nub --set-schedule-time <mins>   Set schedule time in minutes")
+	fmt.Println("  nub --set-schedule <cron>        Set cron schedule (\"off\" to use schedule time)")
+	fmt.Println("  nub --set-quiet-hours <from-to>  Set quiet hours, e.g. 23:00-07:00 (\"off\" to disable)")
 	fmt.Println("  nub --set-prompt <text>          Set custom summarization prompt")
 	fmt.Println("  nub --set-focus <topics>         Set focus topics (comma-separated)")
+	fmt.Println("  nub --set-context-window <n>     Set LLM context window in tokens")
+	fmt.Println("  nub --set-theme <theme>          Set HTML theme (auto, light, dark, high-contrast)")
+	fmt.Println("  nub --set-structured-summaries <on|off>  Ask for structured JSON summaries")
 	fmt.Println()
 	fmt.Println("Utilities:")
 	fmt.Println("  nub --logs                       View logs in pager")
@@ -249,6 +546,7 @@ func showHelp() {
 	fmt.Println("Example config.json:")
 	fmt.Println(`  {
     "sources": ["https://example.com", "https://news.ycombinator.com"],
+    "llm_provider": "openai",
     "llm_api_key": "your-api-key",
     "llm_api_url": "https://api.mistral.ai/v1/chat/completions",
     "llm_api_model": "mistral-small-latest",
@@ -257,65 +555,25 @@ func showHelp() {
   }`)
 }
 
-func runOnce(config *Config) error {
-	if err := validateConfig(config); err != nil {
-		return err
+func validateConfig(config *Config) error {
+	if len(config.Sources) == 0 {
+		return fmt.Errorf("no sources configured")
 	}
-
-	fmt.Println("Starting crawl and summarization...")
-	var allSummaries []string
 	for _, source := range config.Sources {
-		summary, err := processSource(config, source)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", source, err)
-			continue
-		}
-		if config.FocusTopics != "" && summary != "" {
-			allSummaries = append(allSummaries, summary)
-		}
-	}
-
-	if config.FocusTopics != "" && len(allSummaries) > 0 {
-		fmt.Println("Extracting focused content from all summaries...")
-		combinedSummaries := strings.Join(allSummaries, "\n\n---\n\n")
-		focused, err := ExtractFocusedContent(config, combinedSummaries)
-		if err != nil {
-			fmt.Printf("Warning: failed to extract focused content: %v\n", err)
-		} else if focused != "" && focused != "No relevant content found." {
-			if err := StoreCombinedFocusedContent(focused); err != nil {
-				fmt.Printf("Warning: failed to store focused content: %v\n", err)
-			}
+		if err := source.validate(); err != nil {
+			return err
 		}
 	}
-
-	fmt.Println("Done!")
-	return nil
-}
-
-func runDaemon(config *Config) {
-	ticker := time.NewTicker(time.Duration(config.ScheduleMinutes) * time.Minute)
-	defer ticker.Stop()
-
-	if err := runOnce(config); err != nil {
-		fmt.Fprintf(os.Stderr, "Error in initial run: %v\n", err)
-	}
-
-	for range ticker.C {
-		fmt.Printf("\n[%s] Starting scheduled crawl...\n", time.Now().Format(time.RFC3339))
-		if err := runOnce(config); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		}
+	if err := validateSchedule(config); err != nil {
+		return err
 	}
-}
-
-func validateConfig(config *Config) error {
-	if len(config.Sources) == 0 {
-		return fmt.Errorf("no sources configured")
+	if _, err := NewLLMProvider(config); err != nil {
+		return err
 	}
-	if config.LLMAPIKey == "" {
+	if config.LLMAPIKey == "" && config.providerName() != "ollama" {
 		return fmt.Errorf("LLM API key not set, use --set-llm-api-key")
 	}
-	if config.LLMAPIURL == "" {
+	if config.LLMAPIURL == "" && config.providerName() == "openai" {
 		return fmt.Errorf("LLM API URL not set, use --set-llm-api-url")
 	}
 	if config.LLMAPIModel == "" {
@@ -324,42 +582,13 @@ func validateConfig(config *Config) error {
 	return nil
 }
 
-func processSource(config *Config, source string) (string, error) {
-	fmt.Printf("Processing: %s\n", source)
-
-	cached, err := IsCached(source)
-	if err != nil {
-		return "", err
-	}
-
-	var content string
-	if cached {
-		fmt.Printf("  Using cached content for %s\n", source)
-		content, err = GetCachedContent(source)
-		if err != nil {
-			return "", err
-		}
-	} else {
-		fmt.Printf("  Crawling %s\n", source)
-		content, err = CrawlWebsite(source)
-		if err != nil {
-			return "", err
-		}
-		if err := CacheContent(source, content); err != nil {
-			return "", err
-		}
-	}
-
-	fmt.Printf("  Summarizing %s\n", source)
-	summary, err := SummarizeWithAI(config, content, source)
-	if err != nil {
-		return "", err
-	}
+type headerFlags []string
 
-	if err := StoreSummarization(source, summary); err != nil {
-		return "", err
-	}
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
 
-	fmt.Printf("  ✓ Completed %s\n", source)
-	return summary, nil
+func (h *headerFlags) Set(value string) error {
+	*h = append(*h, value)
+	return nil
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Orders of summaries for --sort.
+const (
+	sortConfig  = "config"
+	sortUpdated = "updated"
+	sortName    = "name"
+	sortTag     = "tag"
+)
+
+func parseSortOrder(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", sortConfig:
+		return sortConfig, nil
+	case sortUpdated:
+		return sortUpdated, nil
+	case sortName:
+		return sortName, nil
+	case sortTag, "tags":
+		return sortTag, nil
+	}
+	return "", fmt.Errorf("invalid sort order %q, use config, updated, name or tag", value)
+}
+
+// sortSummaries orders summaries by order. By tag, a source is sorted
+// under the first of its tags alphabetically. Sources that are no longer in
+// the config come after the configured ones, and summaries of the same
+// source keep their order, newest first.
+func sortSummaries(config *Config, summaries []StoredSummary, order string) {
+	rank := map[string]int{}
+	tags := map[string]string{}
+	if config != nil {
+		for i, source := range config.Sources {
+			rank[source.URL] = i
+			for _, tag := range source.Tags {
+				tag = strings.ToLower(tag)
+				if tags[source.URL] == "" || tag < tags[source.URL] {
+					tags[source.URL] = tag
+				}
+			}
+		}
+	}
+	position := func(url string) int {
+		if i, ok := rank[url]; ok {
+			return i
+		}
+		return len(rank)
+	}
+	byConfig := func(a, b StoredSummary) bool {
+		if position(a.URL) != position(b.URL) {
+			return position(a.URL) < position(b.URL)
+		}
+		return a.URL < b.URL
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		switch order {
+		case sortUpdated:
+			if !a.Generated.Equal(b.Generated) {
+				return a.Generated.After(b.Generated)
+			}
+		case sortName:
+			nameA := strings.ToLower(sourceTitle(config, a.URL))
+			nameB := strings.ToLower(sourceTitle(config, b.URL))
+			if nameA != nameB {
+				return nameA < nameB
+			}
+		case sortTag:
+			tagA, tagB := tags[a.URL], tags[b.URL]
+			if tagA != tagB {
+				// Untagged sources go last.
+				return tagB == "" || tagA != "" && tagA < tagB
+			}
+		}
+		return byConfig(a, b)
+	})
+}
+
+// focusContents returns the stored focus summaries: the combined one
+// first, then those of single sources in order.
+func focusContents(config *Config, order string) []string {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil
+	}
+	focusDir := filepath.Join(dataDir, "focus")
+	files, err := os.ReadDir(focusDir)
+	if err != nil {
+		return nil
+	}
+
+	var combined []string
+	var sources []StoredSummary
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".md" {
+			continue
+		}
+		path := filepath.Join(focusDir, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if file.Name() == "combined.md" {
+			combined = append(combined, string(data))
+			continue
+		}
+		focus := StoredSummary{
+			URL:     sourceURLByID(config, strings.TrimSuffix(file.Name(), ".md")),
+			Path:    path,
+			Content: string(data),
+		}
+		if info, err := file.Info(); err == nil {
+			focus.Generated = info.ModTime()
+		}
+		sources = append(sources, focus)
+	}
+
+	sortSummaries(config, sources, order)
+	for _, focus := range sources {
+		combined = append(combined, focus.Content)
+	}
+	return combined
+}
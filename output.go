@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Output formats for --format.
+const (
+	formatText     = "text"
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+	formatNDJSON   = "ndjson"
+)
+
+func parseOutputFormat(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", formatText:
+		return formatText, nil
+	case formatMarkdown, "md":
+		return formatMarkdown, nil
+	case formatJSON:
+		return formatJSON, nil
+	case formatNDJSON:
+		return formatNDJSON, nil
+	}
+	return "", fmt.Errorf("invalid format %q, use json, ndjson, markdown or text", value)
+}
+
+// summaryRecord is a stored summary in --format json and ndjson.
+type summaryRecord struct {
+	Source    string        `json:"source"`
+	URL       string        `json:"url"`
+	Generated time.Time     `json:"generated"`
+	Model     string        `json:"model,omitempty"`
+	Summary   string        `json:"summary"`
+	Items     []SummaryItem `json:"items,omitempty"`
+}
+
+// sourceRecord is a configured source in --format json and ndjson.
+type sourceRecord struct {
+	ID            int        `json:"id"`
+	URL           string     `json:"url"`
+	Name          string     `json:"name,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	Model         string     `json:"model,omitempty"`
+	Schedule      string     `json:"schedule,omitempty"`
+	LastGenerated *time.Time `json:"last_generated,omitempty"`
+}
+
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// encodeRecords writes records as one JSON array, or one JSON object per
+// line for ndjson.
+func encodeRecords[T any](records []T, format string) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+
+	if format == formatJSON {
+		if records == nil {
+			records = []T{}
+		}
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(records)
+		return b.Bytes(), err
+	}
+
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// writeOutput opens output in the pager when stdout is a terminal and
+// writes it to stdout otherwise, so it can be piped. name is the file in
+// the data directory the pager reads.
+func writeOutput(output []byte, name string) error {
+	if !stdoutIsTerminal() {
+		_, err := os.Stdout.Write(output)
+		return err
+	}
+
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+	tempFile := filepath.Join(dataDir, name)
+	if err := os.WriteFile(tempFile, output, 0644); err != nil {
+		return err
+	}
+
+	pager := "less"
+	if os.Getenv("PAGER") != "" {
+		pager = os.Getenv("PAGER")
+	}
+
+	args := []string{tempFile}
+	if filepath.Base(pager) == "less" {
+		// Let less show bold search matches.
+		args = []string{"-R", tempFile}
+	}
+	exec := &execCmd{name: pager, args: args}
+	return exec.runWait()
+}
+
+func summaryRecords(config *Config, summaries []StoredSummary) []summaryRecord {
+	records := make([]summaryRecord, 0, len(summaries))
+	for _, summary := range summaries {
+		records = append(records, summaryRecord{
+			Source:    sourceTitle(config, summary.URL),
+			URL:       summary.URL,
+			Generated: summary.Generated,
+			Model:     summary.Model,
+			Summary:   strings.TrimSpace(stripSummaryHeader(summary.Content)),
+			Items:     summary.Items,
+		})
+	}
+	return records
+}
+
+// ListSourcesFormat prints the configured sources in format.
+func ListSourcesFormat(config *Config, format string) error {
+	if format == formatText {
+		ListSources(config)
+		return nil
+	}
+
+	var records []sourceRecord
+	for i, source := range config.Sources {
+		record := sourceRecord{
+			ID:       i + 1,
+			URL:      source.URL,
+			Name:     source.Name,
+			Tags:     source.Tags,
+			Enabled:  source.IsEnabled(),
+			Model:    config.forSource(source).LLMAPIModel,
+			Schedule: source.scheduleSpec(config),
+		}
+		if summaryDir, ok := sourceDir(sourceID(source.URL)); ok {
+			if path, err := latestSummaryPath(summaryDir); err == nil && path != "" {
+				if summary, err := readStoredSummary(path); err == nil {
+					record.LastGenerated = &summary.Generated
+				}
+			}
+		}
+		records = append(records, record)
+	}
+
+	if format == formatMarkdown {
+		var b strings.Builder
+		b.WriteString("# Sources\n\n")
+		for _, record := range records {
+			fmt.Fprintf(&b, "%d. [%s](%s)", record.ID, sourceTitle(config, record.URL), record.URL)
+			if len(record.Tags) > 0 {
+				fmt.Fprintf(&b, " - %s", strings.Join(record.Tags, ", "))
+			}
+			if !record.Enabled {
+				b.WriteString(" (disabled)")
+			}
+			b.WriteString("\n")
+		}
+		fmt.Print(b.String())
+		return nil
+	}
+
+	data, err := encodeRecords(records, format)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
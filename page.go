@@ -0,0 +1,196 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+//go:embed templates/page.html
+var defaultPageTemplate string
+
+// Themes of the HTML view. "auto" follows the browser's
+// prefers-color-scheme and prefers-contrast settings.
+var pageThemes = []string{"auto", "light", "dark", "high-contrast"}
+
+// PageData is the data model of the page template.
+type PageData struct {
+	anchored map[string]bool
+
+	Title     string
+	Theme     string
+	Generated time.Time
+	Focus     *PageFocus
+	Summaries []PageSummary
+
+	// Head is added to <head>, Nav is shown next to the title and Body
+	// after the summaries. They hold the feed links, search box and
+	// search results of pages served by --serve.
+	Head template.HTML
+	Nav  template.HTML
+	Body template.HTML
+}
+
+// PageFocus is the combined focus summary.
+type PageFocus struct {
+	Topics string
+	HTML   template.HTML
+}
+
+// PageSummary is one stored summary. HTML is the sanitized rendering of
+// the whole stored markdown, including its title and "Generated:" line.
+// Anchor is the id of the first summary of each source on the page, the
+// target of its table of contents entry. Link points to the source's
+// archive when the page is served.
+type PageSummary struct {
+	ID        string
+	Source    string
+	URL       string
+	Generated time.Time
+	Model     string
+	HTML      template.HTML
+	Items     []SummaryItem
+	Anchor    string
+	Link      string
+}
+
+func (c *Config) theme() string {
+	if c == nil || c.Theme == "" {
+		return "auto"
+	}
+	return c.Theme
+}
+
+func validateTheme(theme string) error {
+	if slices.Contains(pageThemes, theme) {
+		return nil
+	}
+	return fmt.Errorf("invalid theme %q, use %s", theme, strings.Join(pageThemes, ", "))
+}
+
+func newPageData(config *Config, title string) PageData {
+	return PageData{
+		Title:     title,
+		Theme:     config.theme(),
+		Generated: time.Now(),
+	}
+}
+
+// addSummary appends summary, giving it an anchor if it is the first
+// summary of its source.
+func (p *PageData) addSummary(summary PageSummary) {
+	if p.anchored == nil {
+		p.anchored = make(map[string]bool)
+	}
+	if !p.anchored[summary.ID] {
+		p.anchored[summary.ID] = true
+		summary.Anchor = "source-" + summary.ID
+	}
+	p.Summaries = append(p.Summaries, summary)
+}
+
+// Contents returns the summaries listed in the table of contents, one
+// per source.
+func (p PageData) Contents() []PageSummary {
+	var contents []PageSummary
+	for _, summary := range p.Summaries {
+		if summary.Anchor != "" {
+			contents = append(contents, summary)
+		}
+	}
+	return contents
+}
+
+func pageSummary(config *Config, summary StoredSummary) PageSummary {
+	return PageSummary{
+		ID:        filepath.Base(filepath.Dir(summary.Path)),
+		Source:    sourceTitle(config, summary.URL),
+		URL:       summary.URL,
+		Generated: summary.Generated,
+		Model:     summary.Model,
+		HTML:      template.HTML(markdownToHTML(summary.Content)),
+		Items:     summary.Items,
+	}
+}
+
+// pageFocus returns the focus summaries, or nil without focus topics.
+func pageFocus(config *Config, order string) *PageFocus {
+	if config == nil || config.FocusTopics == "" {
+		return nil
+	}
+
+	var html strings.Builder
+	for _, content := range focusContents(config, order) {
+		html.WriteString(markdownToHTML(content))
+	}
+	return &PageFocus{Topics: config.FocusTopics, HTML: template.HTML(html.String())}
+}
+
+func getTemplatesDir() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), "templates"), nil
+}
+
+var pageFuncs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Local().Format("2006-01-02 15:04")
+	},
+}
+
+// loadPageTemplate parses the built-in template, then the user's *.html
+// files, whose definitions replace the built-in ones of the same name.
+// It runs for every page, so template edits show up on the next reload.
+func loadPageTemplate() (*template.Template, error) {
+	tmpl, err := template.New("nub").Funcs(pageFuncs).Parse(defaultPageTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := getTemplatesDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil || len(files) == 0 {
+		return tmpl, nil
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.New(filepath.Base(file)).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("template %s: %v", file, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// renderFragment executes tmpl into HTML to embed in a page.
+func renderFragment(tmpl *template.Template, data any) (template.HTML, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
+}
+
+func renderPage(data PageData) (string, error) {
+	tmpl, err := loadPageTemplate()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.ExecuteTemplate(&b, "page", data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
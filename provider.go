@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LLMProvider interface {
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+const (
+	defaultAnthropicURL = "https://api.anthropic.com/v1/messages"
+	defaultOllamaURL    = "http://localhost:11434/api/chat"
+	defaultGeminiURL    = "https://generativelanguage.googleapis.com/v1beta/models/{model}:generateContent"
+	anthropicVersion    = "2023-06-01"
+
+	defaultLLMTimeout    = 120 * time.Second
+	defaultLLMMaxRetries = 3
+	llmBackoffBase       = time.Second
+	llmBackoffMax        = time.Minute
+	maxRetryAfter        = 5 * time.Minute
+)
+
+var llmProviders = []string{"openai", "anthropic", "ollama", "gemini"}
+
+func NewLLMProvider(config *Config) (LLMProvider, error) {
+	endpoint := config.LLMAPIURL
+	client := newLLMClient(config)
+	switch config.providerName() {
+	case "openai":
+		return &openAIProvider{client: client, url: endpoint, key: config.LLMAPIKey, model: config.LLMAPIModel}, nil
+	case "anthropic":
+		if endpoint == "" {
+			endpoint = defaultAnthropicURL
+		}
+		return &anthropicProvider{client: client, url: endpoint, key: config.LLMAPIKey, model: config.LLMAPIModel, maxTokens: config.maxOutputTokens()}, nil
+	case "ollama":
+		if endpoint == "" {
+			endpoint = defaultOllamaURL
+		}
+		return &ollamaProvider{client: client, url: endpoint, key: config.LLMAPIKey, model: config.LLMAPIModel}, nil
+	case "gemini":
+		if endpoint == "" {
+			endpoint = defaultGeminiURL
+		}
+		endpoint = strings.ReplaceAll(endpoint, "{model}", url.PathEscape(config.LLMAPIModel))
+		return &geminiProvider{client: client, url: endpoint, key: config.LLMAPIKey, maxTokens: config.maxOutputTokens()}, nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q (supported: %s)", config.LLMProvider, strings.Join(llmProviders, ", "))
+}
+
+func (c *Config) providerName() string {
+	name := strings.ToLower(strings.TrimSpace(c.LLMProvider))
+	if name == "" {
+		return "openai"
+	}
+	return name
+}
+
+func (c *Config) maxOutputTokens() int {
+	window := c.ContextWindow
+	if window <= 0 {
+		window = defaultContextWindow
+	}
+	return window / 4
+}
+
+type llmClient struct {
+	http       *http.Client
+	maxRetries int
+}
+
+func newLLMClient(config *Config) *llmClient {
+	timeout := time.Duration(config.LLMTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultLLMTimeout
+	}
+
+	retries := config.LLMMaxRetries
+	if retries == 0 {
+		retries = defaultLLMMaxRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+
+	return &llmClient{
+		http:       &http.Client{Timeout: timeout},
+		maxRetries: retries,
+	}
+}
+
+func (c *llmClient) postJSON(ctx context.Context, url string, headers map[string]string, payload, out any) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, retryAfter, err := c.post(ctx, url, headers, jsonData)
+		if err == nil {
+			return json.Unmarshal(body, out)
+		}
+
+		var apiErr *llmAPIError
+		retryable := !errors.As(err, &apiErr) || apiErr.retryable()
+		if !retryable || attempt >= c.maxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		delay := retryAfter
+		if delay <= 0 {
+			delay = backoffDelay(attempt)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *llmClient) post(ctx context.Context, url string, headers map[string]string, jsonData []byte) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, 0, &llmAPIError{err: err}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &llmAPIError{
+			status: resp.StatusCode,
+			err:    fmt.Errorf("LLM API error: %s - %s", resp.Status, string(body)),
+		}
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), apiErr
+	}
+
+	return body, 0, nil
+}
+
+type llmAPIError struct {
+	status int
+	err    error
+}
+
+func (e *llmAPIError) Error() string {
+	return e.err.Error()
+}
+
+func (e *llmAPIError) retryable() bool {
+	switch e.status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		529:
+		return true
+	}
+	return false
+}
+
+func backoffDelay(attempt int) time.Duration {
+	delay := llmBackoffBase << attempt
+	if delay <= 0 || delay > llmBackoffMax {
+		delay = llmBackoffMax
+	}
+	// Full jitter in the upper half keeps concurrent retries from
+	// hitting the provider in lockstep.
+	return delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1))
+}
+
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if when, err := http.ParseTime(value); err == nil {
+		delay = time.Until(when)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return delay
+}
+
+type ChatCompletionRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatCompletionResponse struct {
+	Choices []Choice `json:"choices"`
+}
+
+type Choice struct {
+	Message Message `json:"message"`
+}
+
+type openAIProvider struct {
+	client *llmClient
+	url    string
+	key    string
+	model  string
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	reqBody := ChatCompletionRequest{
+		Model:    p.model,
+		Messages: []Message{{Role: "user", Content: prompt}},
+		Stream:   false,
+	}
+
+	headers := map[string]string{}
+	if p.key != "" {
+		headers["Authorization"] = "Bearer " + p.key
+	}
+
+	var chatResp ChatCompletionResponse
+	if err := p.client.postJSON(ctx, p.url, headers, reqBody, &chatResp); err != nil {
+		return "", err
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no response from LLM")
+	}
+	return strings.TrimSpace(chatResp.Choices[0].Message.Content), nil
+}
+
+type anthropicRequest struct {
+	Model     string    `json:"model"`
+	MaxTokens int       `json:"max_tokens"`
+	Messages  []Message `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+type anthropicProvider struct {
+	client    *llmClient
+	url       string
+	key       string
+	model     string
+	maxTokens int
+}
+
+func (p *anthropicProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	reqBody := anthropicRequest{
+		Model:     p.model,
+		MaxTokens: p.maxTokens,
+		Messages:  []Message{{Role: "user", Content: prompt}},
+	}
+
+	headers := map[string]string{
+		"x-api-key":         p.key,
+		"anthropic-version": anthropicVersion,
+	}
+
+	var msgResp anthropicResponse
+	if err := p.client.postJSON(ctx, p.url, headers, reqBody, &msgResp); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, block := range msgResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no response from LLM")
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+type ollamaResponse struct {
+	Message Message `json:"message"`
+}
+
+type ollamaProvider struct {
+	client *llmClient
+	url    string
+	key    string
+	model  string
+}
+
+func (p *ollamaProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	reqBody := ChatCompletionRequest{
+		Model:    p.model,
+		Messages: []Message{{Role: "user", Content: prompt}},
+		Stream:   false,
+	}
+
+	headers := map[string]string{}
+	if p.key != "" {
+		headers["Authorization"] = "Bearer " + p.key
+	}
+
+	var chatResp ollamaResponse
+	if err := p.client.postJSON(ctx, p.url, headers, reqBody, &chatResp); err != nil {
+		return "", err
+	}
+
+	if chatResp.Message.Content == "" {
+		return "", fmt.Errorf("no response from LLM")
+	}
+	return strings.TrimSpace(chatResp.Message.Content), nil
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	Contents         []geminiContent `json:"contents"`
+	GenerationConfig struct {
+		MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+	} `json:"generationConfig"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+}
+
+type geminiProvider struct {
+	client    *llmClient
+	url       string
+	key       string
+	maxTokens int
+}
+
+func (p *geminiProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	var reqBody geminiRequest
+	reqBody.Contents = []geminiContent{{Role: "user", Parts: []geminiPart{{Text: prompt}}}}
+	reqBody.GenerationConfig.MaxOutputTokens = p.maxTokens
+
+	headers := map[string]string{"x-goog-api-key": p.key}
+
+	var genResp geminiResponse
+	if err := p.client.postJSON(ctx, p.url, headers, reqBody, &genResp); err != nil {
+		return "", err
+	}
+
+	if len(genResp.Candidates) == 0 {
+		return "", fmt.Errorf("no response from LLM")
+	}
+
+	var text strings.Builder
+	for _, part := range genResp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no response from LLM")
+	}
+	return strings.TrimSpace(text.String()), nil
+}
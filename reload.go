@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const configPollInterval = 2 * time.Second
+
+// watchConfig reloads the daemon config when hup receives SIGHUP and
+// whenever config.json changes on disk. The file is polled rather than
+// watched so that editors which replace the file instead of writing it in
+// place are picked up too.
+func (d *daemon) watchConfig(ctx context.Context, hup <-chan os.Signal) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		log.Printf("Warning: config reload unavailable: %v\n", err)
+		return
+	}
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(configPath)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Printf("SIGHUP received, reloading config\n")
+			last, _ = os.Stat(configPath)
+		case <-ticker.C:
+			info, err := os.Stat(configPath)
+			if err != nil || !configChanged(last, info) {
+				continue
+			}
+			last = info
+			log.Printf("Config file changed, reloading\n")
+		}
+
+		if err := d.reloadConfig(configPath); err != nil {
+			log.Printf("Config reload rejected, keeping previous config: %v\n", err)
+		}
+	}
+}
+
+func configChanged(last, info os.FileInfo) bool {
+	if last == nil {
+		return true
+	}
+	return !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size()
+}
+
+// reloadConfig loads and validates config.json and swaps it in. A run in
+// progress keeps the config it started with.
+func (d *daemon) reloadConfig(configPath string) error {
+	// LoadConfig writes a default config when the file is missing, which
+	// would silently replace a config that is only being moved around.
+	if _, err := os.Stat(configPath); err != nil {
+		return err
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if config.ScheduleMinutes <= 0 {
+		config.ScheduleMinutes = 15
+	}
+	if err := validateConfig(config); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.config = config
+	d.mu.Unlock()
+
+	select {
+	case d.reloaded <- struct{}{}:
+	default:
+	}
+
+	log.Printf("Config reloaded: %d sources, schedule %s\n", len(config.Sources), config.scheduleSpec())
+	return nil
+}
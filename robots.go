@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultUserAgent = "nub (+https://github.com/x0ptr/nub)"
+	robotsTTL        = 24 * time.Hour
+	maxRobotsSize    = 500 * 1024
+	maxCrawlDelay    = time.Minute
+)
+
+var errRobotsDisallowed = errors.New("blocked by robots.txt")
+
+func (c *Config) userAgent() string {
+	if c.UserAgent == "" {
+		return defaultUserAgent
+	}
+	return c.UserAgent
+}
+
+// robotsRules are the rules of one robots.txt that apply to nub.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+
+	// unavailable is set when robots.txt could not be read; everything
+	// is disallowed then.
+	unavailable error
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// parseRobots parses a robots.txt and keeps the groups for userAgent's
+// product token, or the "*" groups if none names it.
+func parseRobots(body, userAgent string) *robotsRules {
+	token := strings.ToLower(strings.Fields(userAgent + " x")[0])
+	token, _, _ = strings.Cut(token, "/")
+
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		case "allow", "disallow":
+			// An empty Disallow allows everything and adds no rule.
+			if current != nil && value != "" {
+				current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		inAgents = false
+	}
+
+	var named, wildcard []*robotsGroup
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if agent == token {
+				named = append(named, group)
+				break
+			}
+			if agent == "*" {
+				wildcard = append(wildcard, group)
+				break
+			}
+		}
+	}
+	if len(named) == 0 {
+		named = wildcard
+	}
+
+	rules := &robotsRules{}
+	for _, group := range named {
+		rules.rules = append(rules.rules, group.rules...)
+		rules.crawlDelay = max(rules.crawlDelay, group.crawlDelay)
+	}
+	rules.crawlDelay = min(rules.crawlDelay, maxCrawlDelay)
+	return rules
+}
+
+// allowed reports whether path (with query) may be fetched. The longest
+// matching rule wins, and Allow wins a tie.
+func (r *robotsRules) allowed(path string) bool {
+	if r.unavailable != nil {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if length := len(rule.pattern); length > best || (length == best && rule.allow) {
+			best = length
+			allow = rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches a robots.txt path pattern, where * matches any run
+// of characters and a trailing $ anchors the end of the path.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
+
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsRules
+}
+
+// allowed checks rawURL against its host's robots.txt, fetching it once
+// per run, and applies the host's Crawl-delay to later requests.
+func (l *crawlLimiter) allowed(ctx context.Context, rawURL string) error {
+	if l == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	site := u.Scheme + "://" + strings.ToLower(u.Host)
+
+	l.mu.Lock()
+	entry, ok := l.robots[site]
+	if !ok {
+		entry = &robotsEntry{}
+		l.robots[site] = entry
+	}
+	l.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.rules = l.loadRobots(ctx, site)
+		if entry.rules.crawlDelay > 0 {
+			l.setCrawlDelay(hostKey(rawURL), entry.rules.crawlDelay)
+		}
+	})
+
+	if err := entry.rules.unavailable; err != nil {
+		return fmt.Errorf("robots.txt unavailable: %v", err)
+	}
+	if !entry.rules.allowed(u.RequestURI()) {
+		return errRobotsDisallowed
+	}
+	return nil
+}
+
+// loadRobots returns the robots.txt rules of site from the disk cache or
+// the network. A missing robots.txt allows everything. When it cannot be
+// fetched, a stale cached copy is used if there is one, otherwise the site
+// is treated as fully disallowed for this run.
+func (l *crawlLimiter) loadRobots(ctx context.Context, site string) *robotsRules {
+	cachePath, err := getRobotsCachePath(site)
+	if err != nil {
+		return &robotsRules{unavailable: err}
+	}
+
+	if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < robotsTTL {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			return parseRobots(string(data), l.userAgent)
+		}
+	}
+
+	body, err := l.fetchRobots(ctx, site)
+	if err != nil {
+		if data, rerr := os.ReadFile(cachePath); rerr == nil {
+			return parseRobots(string(data), l.userAgent)
+		}
+		return &robotsRules{unavailable: err}
+	}
+
+	if err := writeFileAtomic(cachePath, []byte(body), 0644); err != nil {
+		return &robotsRules{unavailable: err}
+	}
+	return parseRobots(body, l.userAgent)
+}
+
+func (l *crawlLimiter) fetchRobots(ctx context.Context, site string) (string, error) {
+	robotsURL := site + "/robots.txt"
+	release, err := l.acquire(ctx, robotsURL)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", l.userAgent)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+		return string(data), err
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests:
+		return "", nil
+	}
+	return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+}
+
+func getRobotsCachePath(site string) (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	robotsDir := filepath.Join(dataDir, "cache", "robots")
+	if err := os.MkdirAll(robotsDir, 0755); err != nil {
+		return "", err
+	}
+
+	hash := md5.Sum([]byte(site))
+	return filepath.Join(robotsDir, hex.EncodeToString(hash[:])+".txt"), nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCrawlConcurrency = 4
+	defaultLLMConcurrency   = 2
+	defaultHostDelay        = time.Second
+	defaultShutdownGrace    = 30 * time.Second
+)
+
+var errSkipped = errors.New("skipped, shutting down")
+
+type sourceResult struct {
+	Source  string
+	Summary string
+	Err     error
+}
+
+// runLog reports the progress of a run. Warnings and errors have their
+// own functions so the command line can print them to stderr.
+type runLog struct {
+	logf   func(string, ...any)
+	warnf  func(string, ...any)
+	errorf func(string, ...any)
+}
+
+// daemonLog logs everything to the daemon's log file.
+var daemonLog = runLog{logf: log.Printf, warnf: log.Printf, errorf: log.Printf}
+
+// consoleLog is the log of runs started from the command line. Warnings
+// and errors go to stderr so they are not lost when stdout is redirected.
+var consoleLog = runLog{logf: printf, warnf: eprintf, errorf: eprintf}
+
+type runner struct {
+	ctx     context.Context
+	config  *Config
+	force   bool
+	logs    runLog
+	crawl   *crawlLimiter
+	llmSlot chan struct{}
+}
+
+func (c *Config) crawlConcurrency() int {
+	if c.CrawlConcurrency <= 0 {
+		return defaultCrawlConcurrency
+	}
+	return c.CrawlConcurrency
+}
+
+func (c *Config) llmConcurrency() int {
+	if c.LLMConcurrency <= 0 {
+		return defaultLLMConcurrency
+	}
+	return c.LLMConcurrency
+}
+
+func (c *Config) hostDelay() time.Duration {
+	if c.HostDelaySeconds < 0 {
+		return 0
+	}
+	if c.HostDelaySeconds == 0 {
+		return defaultHostDelay
+	}
+	return time.Duration(c.HostDelaySeconds) * time.Second
+}
+
+func (c *Config) shutdownGrace() time.Duration {
+	if c.ShutdownGraceSeconds <= 0 {
+		return defaultShutdownGrace
+	}
+	return time.Duration(c.ShutdownGraceSeconds) * time.Second
+}
+
+// graceContext returns a context that is cancelled grace after ctx is,
+// giving in-flight work a bounded time to finish cleanly.
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	work, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return work, func() {
+		stop()
+		cancel()
+	}
+}
+
+// runOnce crawls and summarizes all enabled sources.
+func runOnce(ctx context.Context, config *Config, force bool, logs runLog) ([]sourceResult, error) {
+	return runBatch(ctx, config, config.enabledSources(), force, logs)
+}
+
+// runBatch crawls and summarizes the given sources, then refreshes the
+// focus summary. Cancelling ctx stops new sources from starting; sources
+// already in flight get the configured grace period before their requests
+// are aborted.
+func runBatch(ctx context.Context, config *Config, sources []Source, force bool, logs runLog) ([]sourceResult, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	// Sources can use their own model, so each source builds its own
+	// provider; this one is for the focus step.
+	provider, err := NewLLMProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	work, cancel := graceContext(ctx, config.shutdownGrace())
+	defer cancel()
+
+	logs.logf("Starting crawl and summarization...\n")
+	results := runSources(ctx, work, config, sources, force, logs)
+
+	for _, result := range results {
+		if result.Err != nil {
+			logs.errorf("Error processing %s: %v\n", result.Source, result.Err)
+		}
+	}
+	var allSummaries []string
+	if config.FocusTopics != "" {
+		allSummaries = focusSummaries(config, results)
+	}
+
+	if ctx.Err() != nil {
+		logs.logf("Run interrupted, skipping focus extraction\n")
+		return results, fmt.Errorf("run interrupted")
+	}
+
+	if config.FocusTopics != "" && len(allSummaries) > 0 {
+		logs.logf("Extracting focused content from all summaries...\n")
+		combinedSummaries := strings.Join(allSummaries, "\n\n---\n\n")
+		focused, err := ExtractFocusedContent(work, config, provider, combinedSummaries)
+		if err != nil {
+			logs.warnf("Warning: failed to extract focused content: %v\n", err)
+		} else if focused != "" && focused != "No relevant content found." {
+			if err := StoreCombinedFocusedContent(focused); err != nil {
+				logs.warnf("Warning: failed to store focused content: %v\n", err)
+			}
+		}
+	}
+
+	if err := WriteFeeds(config); err != nil {
+		logs.warnf("Warning: failed to write digest feeds: %v\n", err)
+	}
+
+	logs.logf("Done!\n")
+	return results, nil
+}
+
+// focusSummaries collects the summaries the focus step works on, in config
+// order. Sources that were not part of this run contribute their latest
+// stored summary; sources that failed in this run are left out.
+func focusSummaries(config *Config, results []sourceResult) []string {
+	byURL := make(map[string]sourceResult, len(results))
+	for _, result := range results {
+		byURL[result.Source] = result
+	}
+
+	var summaries []string
+	for _, source := range config.enabledSources() {
+		summary := ""
+		if result, ok := byURL[source.URL]; ok {
+			if result.Err != nil {
+				continue
+			}
+			summary = result.Summary
+		} else {
+			summary, _ = GetStoredSummary(source.URL)
+		}
+		if summary != "" {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries
+}
+
+// runSources processes sources on a bounded worker pool. Crawling and
+// summarizing have separate limits, and the results keep the order of
+// sources so the focus step sees them as configured. Once ctx is done no
+// new source is started; work bounds the sources already running.
+func runSources(ctx, work context.Context, config *Config, sources []Source, force bool, logs runLog) []sourceResult {
+	r := &runner{
+		ctx:     work,
+		config:  config,
+		force:   force,
+		logs:    logs,
+		crawl:   newCrawlLimiter(config.crawlConcurrency(), config.hostDelay(), config.userAgent()),
+		llmSlot: make(chan struct{}, config.llmConcurrency()),
+	}
+
+	results := make([]sourceResult, len(sources))
+	for i, source := range sources {
+		results[i] = sourceResult{Source: source.URL, Err: errSkipped}
+	}
+	jobs := make(chan int)
+
+	workers := min(len(sources), config.crawlConcurrency()+config.llmConcurrency())
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				summary, err := r.processSource(sources[i])
+				results[i] = sourceResult{Source: sources[i].URL, Summary: summary, Err: err}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range sources {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func (r *runner) processSource(source Source) (string, error) {
+	r.logs.logf("Processing: %s\n", source.URL)
+	config := r.config.forSource(source)
+
+	content, err := fetchSource(r.ctx, config, r.crawl, source, r.logs.logf)
+	if err != nil {
+		return "", err
+	}
+
+	var text string
+	if source.FollowLinks > 0 {
+		text, err = followArticles(r.ctx, config, r.crawl, source, content, r.logs)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		text = extractContent(content, source.URL, source.Selector)
+	}
+
+	select {
+	case r.llmSlot <- struct{}{}:
+	case <-r.ctx.Done():
+		return "", r.ctx.Err()
+	}
+	summary, err := summarizeContent(r.ctx, config, source, text, r.force, r.logs.logf)
+	<-r.llmSlot
+	if err != nil {
+		return "", err
+	}
+
+	r.logs.logf("  ✓ Completed %s\n", source.URL)
+	return summary, nil
+}
+
+func summarizeContent(ctx context.Context, config *Config, src Source, text string, force bool, logf func(string, ...any)) (string, error) {
+	source := src.URL
+	hash := contentHash(config, text)
+
+	if !force {
+		state, err := GetSourceState(source)
+		if err != nil {
+			return "", err
+		}
+		if state != nil && state.ContentHash == hash {
+			summary, err := GetStoredSummary(source)
+			if err != nil {
+				return "", err
+			}
+			if summary != "" {
+				logf("  Content unchanged, reusing summary for %s\n", source)
+				return summary, nil
+			}
+		}
+	}
+
+	provider, err := NewLLMProvider(config)
+	if err != nil {
+		return "", err
+	}
+
+	logf("  Summarizing %s\n", source)
+	var summary string
+	var structured *StructuredSummary
+	if config.StructuredSummaries {
+		items, err := SummarizeStructured(ctx, config, provider, text, source)
+		if err != nil {
+			return "", err
+		}
+		summary = itemsToMarkdown(items)
+		structured = &StructuredSummary{URL: source, Model: config.LLMAPIModel, Items: items}
+	} else {
+		summary, err = SummarizeWithAI(ctx, config, provider, text, source)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := StoreSummarization(source, config.LLMAPIModel, summary, structured); err != nil {
+		return "", err
+	}
+
+	if err := StoreSourceState(source, &SourceState{URL: source, ContentHash: hash, SummarizedAt: time.Now()}); err != nil {
+		return "", err
+	}
+
+	return summary, nil
+}
+
+func printf(format string, args ...any) {
+	fmt.Printf(format, args...)
+}
+
+func eprintf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedElements are the elements markdown renders to, with the
+// attributes each may keep. Other elements are dropped but their text is
+// kept, except droppedElements, which are removed with their content.
+var allowedElements = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil,
+	atom.H1: {"id"}, atom.H2: {"id"}, atom.H3: {"id"},
+	atom.H4: {"id"}, atom.H5: {"id"}, atom.H6: {"id"},
+	atom.Ul: nil, atom.Ol: {"start"}, atom.Li: nil,
+	atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.Blockquote: nil, atom.Pre: nil, atom.Code: {"class"},
+	atom.Em: nil, atom.Strong: nil, atom.I: nil, atom.B: nil, atom.Del: nil, atom.S: nil,
+	atom.Sup: nil, atom.Sub: nil, atom.A: {"href", "title", "target"},
+	atom.Table: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil,
+	atom.Tr: nil, atom.Th: {"align"}, atom.Td: {"align"},
+}
+
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Iframe: true, atom.Frame: true,
+	atom.Frameset: true, atom.Object: true, atom.Embed: true, atom.Applet: true,
+	atom.Noscript: true, atom.Template: true, atom.Svg: true, atom.Math: true,
+	atom.Form: true, atom.Button: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true, atom.Link: true, atom.Meta: true, atom.Base: true,
+	atom.Title: true, atom.Head: true,
+}
+
+// sanitizedIDPrefix is put in front of the ids in model output, and of
+// the #anchors linking to them, so they cannot clash with the ids of the
+// page around them.
+const sanitizedIDPrefix = "md-"
+
+var (
+	safeIDPattern      = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	safeCodeClass      = regexp.MustCompile(`^language-[A-Za-z0-9_+-]+$`)
+	safeNumberPattern  = regexp.MustCompile(`^[0-9]{1,6}$`)
+	allowedAlignments  = map[string]bool{"left": true, "right": true, "center": true}
+	allowedLinkSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+)
+
+// sanitizeHTML keeps only the allow-listed elements and attributes of an
+// HTML fragment, so model output cannot run scripts or restyle the page.
+func sanitizeHTML(fragment string) string {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), context)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		sanitizeNode(&b, n)
+	}
+	return b.String()
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if droppedElements[n.DataAtom] {
+		return
+	}
+	attrs, ok := allowedElements[n.DataAtom]
+	if !ok {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			sanitizeNode(b, c)
+		}
+		return
+	}
+
+	b.WriteString("<" + n.Data)
+	blank := false
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || !slices.Contains(attrs, attr.Key) {
+			continue
+		}
+		value, ok := sanitizeAttr(n.DataAtom, attr.Key, attr.Val)
+		if !ok {
+			continue
+		}
+		if attr.Key == "target" {
+			blank = true
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+	}
+	if blank {
+		b.WriteString(` rel="noopener noreferrer"`)
+	}
+	b.WriteString(">")
+
+	if n.DataAtom == atom.Br || n.DataAtom == atom.Hr {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c)
+	}
+	b.WriteString("</" + n.Data + ">")
+}
+
+func sanitizeAttr(element atom.Atom, key, value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	switch key {
+	case "href":
+		if anchor, ok := strings.CutPrefix(value, "#"); ok && anchor != "" {
+			return "#" + sanitizedIDPrefix + anchor, true
+		}
+		return value, safeLink(value)
+	case "target":
+		return value, value == "_blank"
+	case "id":
+		return sanitizedIDPrefix + value, safeIDPattern.MatchString(value)
+	case "class":
+		return value, element == atom.Code && safeCodeClass.MatchString(value)
+	case "align":
+		return value, allowedAlignments[strings.ToLower(value)]
+	case "start":
+		return value, safeNumberPattern.MatchString(value)
+	case "title":
+		return value, true
+	}
+	return "", false
+}
+
+// safeLink allows http, https and mailto links, and relative links
+// such as #anchors.
+func safeLink(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" {
+		return u.Opaque == "" && !strings.HasPrefix(href, "//")
+	}
+	return allowedLinkSchemes[strings.ToLower(u.Scheme)]
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"markdown output", `<h2 id="news">News</h2><p><strong>Go</strong> <em>1.26</em> <code>x</code></p>`,
+			`<h2 id="md-news">News</h2><p><strong>Go</strong> <em>1.26</em> <code>x</code></p>`},
+		{"list", `<ol start="3"><li>a</li></ol>`, `<ol start="3"><li>a</li></ol>`},
+		{"code class", `<pre><code class="language-go">x</code></pre>`, `<pre><code class="language-go">x</code></pre>`},
+		{"bad code class", `<code class="x onload">x</code>`, `<code>x</code>`},
+		{"link", `<a href="https://go.dev/blog" target="_blank">Go</a>`,
+			`<a href="https://go.dev/blog" target="_blank" rel="noopener noreferrer">Go</a>`},
+		{"mailto", `<a href="mailto:a@example.com">mail</a>`, `<a href="mailto:a@example.com">mail</a>`},
+		{"relative link", `<a href="/about">about</a>`, `<a href="/about">about</a>`},
+		{"anchor link", `<a href="#news">news</a>`, `<a href="#md-news">news</a>`},
+		{"id taking a page anchor", `<h2 id="source-2220c4a70bf699eb56d1c07567daa307">x</h2>`,
+			`<h2 id="md-source-2220c4a70bf699eb56d1c07567daa307">x</h2>`},
+		{"id with markup", `<h2 id="a&quot; onclick=&quot;x">x</h2>`, `<h2>x</h2>`},
+		{"other target", `<a href="https://a.example" target="_top">a</a>`, `<a href="https://a.example">a</a>`},
+
+		{"script", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+		{"script upper case", `<SCRIPT>alert(1)</SCRIPT>`, ``},
+		{"style", `<style>body{display:none}</style><p>x</p>`, `<p>x</p>`},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`, ``},
+		{"svg", `<svg onload="alert(1)"><script>alert(1)</script></svg>`, ``},
+		{"form", `<form action="https://evil.example"><input name="q"></form>`, ``},
+		{"unknown element keeps text", `<div><span>text</span></div>`, `text`},
+		{"img", `<img src="x" onerror="alert(1)">`, ``},
+
+		{"event handler", `<p onclick="alert(1)">x</p>`, `<p>x</p>`},
+		{"event handler on link", `<a href="https://a.example" onmouseover="alert(1)">a</a>`, `<a href="https://a.example">a</a>`},
+		{"style attribute", `<p style="position:fixed">x</p>`, `<p>x</p>`},
+
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript upper case", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript leading space", `<a href=" javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"data href", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a>x</a>`},
+		{"vbscript href", `<a href="vbscript:msgbox(1)">x</a>`, `<a>x</a>`},
+		{"protocol-relative href", `<a href="//evil.example">x</a>`, `<a>x</a>`},
+		{"entity encoded scheme", `<a href="jav&#x61;script:alert(1)">x</a>`, `<a>x</a>`},
+		{"entity encoded colon", `<a href="javascript&colon;alert(1)">x</a>`, `<a>x</a>`},
+		{"decimal entities", `<a href="&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;alert(1)">x</a>`, `<a>x</a>`},
+		{"tab in scheme", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"newline in scheme", "<a href=\"java\nscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"control character", "<a href=\"\x01javascript:alert(1)\">x</a>", `<a>x</a>`},
+		{"encoded tab", `<a href="java&#9;script:alert(1)">x</a>`, `<a>x</a>`},
+
+		{"text is escaped", `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`, `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+		{"attribute quotes", `<a href="https://a.example/?q=&quot;&gt;&lt;script&gt;">x</a>`,
+			`<a href="https://a.example/?q=&#34;&gt;&lt;script&gt;">x</a>`},
+		{"comment", `<p>a<!-- <script>alert(1)</script> -->b</p>`, `<p>ab</p>`},
+		{"unclosed tag", `<p>a<script>alert(1)`, `<p>a</p>`},
+	}
+	for _, tt := range tests {
+		if got := sanitizeHTML(tt.in); got != tt.want {
+			t.Errorf("%s: sanitizeHTML(%q)\n got %q\nwant %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownToHTMLSanitized(t *testing.T) {
+	md := "# Title\n\n[click](javascript:alert(1)) <script>alert(1)</script>\n\n<img src=x onerror=alert(1)>\n"
+	got := markdownToHTML(md)
+	for _, bad := range []string{"javascript:", "<script", "onerror", "<img"} {
+		if strings.Contains(strings.ToLower(got), bad) {
+			t.Errorf("markdownToHTML output contains %q:\n%s", bad, got)
+		}
+	}
+	if !strings.Contains(got, `<h1 id="md-title">Title</h1>`) {
+		t.Errorf("markdownToHTML lost the heading:\n%s", got)
+	}
+}
+
+func TestSearchResultsHTMLFiltersLinks(t *testing.T) {
+	results := []SearchResult{{URL: "javascript:alert(1)", Snippet: []snippetPart{{Text: "<b>", Match: true}}}}
+	got, err := searchResultsHTML(nil, `"><script>`, results, func(r SearchResult) string { return r.URL })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{`href="javascript:`, "<script>", "<b>"} {
+		if strings.Contains(string(got), bad) {
+			t.Errorf("search results contain %q:\n%s", bad, got)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule yields the run times of a source. Specs are either a standard
+// five-field cron expression (minute hour day-of-month month day-of-week),
+// one of the @hourly/@daily/@weekly/@monthly/@yearly shorthands, or
+// "@every <duration>".
+type schedule interface {
+	next(after time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (e everySchedule) next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var cronShorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		if every < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least 1m", spec)
+		}
+		return everySchedule(every), nil
+	}
+	if expr, ok := cronShorthands[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields", spec)
+	}
+
+	c := &cronSchedule{}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: minute: %v", spec, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: hour: %v", spec, err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of month: %v", spec, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: month: %v", spec, err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of week: %v", spec, err)
+	}
+	// 7 is an alias for Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	if c.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("invalid schedule %q: never matches", spec)
+	}
+	return c, nil
+}
+
+func parseCronField(field string, low, high int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		start, end := low, high
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = parseCronValue(first, low, high, names); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = parseCronValue(last, low, high, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = high
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, low, high int, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if n < low || n > high {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, low, high)
+	}
+	return n, nil
+}
+
+func (c *cronSchedule) next(after time.Time) time.Time {
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, after.Location()).Add(time.Minute)
+
+	// Give up after five years, e.g. for "0 0 30 2 *".
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: when both day fields are restricted, a day
+// matching either of them is enough.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// quietHours is a daily window, given as "HH:MM-HH:MM", in which no
+// scheduled run starts. The window may wrap around midnight.
+type quietHours struct {
+	start, end int // minutes since midnight
+}
+
+func parseQuietHours(value string) (*quietHours, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	first, last, ok := strings.Cut(value, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid quiet hours %q: expected HH:MM-HH:MM", value)
+	}
+	start, err := parseClock(first)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours %q: %v", value, err)
+	}
+	end, err := parseClock(last)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours %q: %v", value, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid quiet hours %q: empty window", value)
+	}
+	return &quietHours{start: start, end: end}, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q *quietHours) contains(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return minute >= q.start && minute < q.end
+	}
+	return minute >= q.start || minute < q.end
+}
+
+// after returns t, or the end of the quiet window if t falls inside it.
+func (q *quietHours) after(t time.Time) time.Time {
+	if !q.contains(t) {
+		return t
+	}
+	end := time.Date(t.Year(), t.Month(), t.Day(), q.end/60, q.end%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// defaultScheduleMinutes is the interval between runs when none is
+// configured, and the fallback for a schedule that cannot be parsed.
+const defaultScheduleMinutes = 15
+
+func (c *Config) scheduleSpec() string {
+	if c.Schedule != "" {
+		return c.Schedule
+	}
+	minutes := c.ScheduleMinutes
+	if minutes <= 0 {
+		minutes = defaultScheduleMinutes
+	}
+	return fmt.Sprintf("@every %dm", minutes)
+}
+
+func (c *Config) scheduleJitter() time.Duration {
+	if c.ScheduleJitterSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(c.ScheduleJitterSeconds) * time.Second
+}
+
+func (s Source) scheduleSpec(config *Config) string {
+	if s.Schedule != "" {
+		return s.Schedule
+	}
+	return config.scheduleSpec()
+}
+
+// nextRun returns when a source should run next, given when it last ran.
+// A source that has never run, or whose run was missed while the daemon
+// was stopped or the machine asleep, is due right away. Jitter spreads
+// sources sharing a schedule, and runs never start in quiet hours. If the
+// source's schedule cannot be parsed it runs at the default interval, and
+// the parse error is returned.
+func nextRun(config *Config, source Source, last, now time.Time) (time.Time, error) {
+	due := now
+	sched, err := parseSchedule(source.scheduleSpec(config))
+	if !last.IsZero() {
+		if err == nil {
+			due = sched.next(last)
+			if jitter := config.scheduleJitter(); jitter > 0 {
+				due = due.Add(rand.N(jitter))
+			}
+		} else {
+			due = last.Add(defaultScheduleMinutes * time.Minute)
+		}
+		if due.IsZero() || due.Before(now) {
+			due = now
+		}
+	}
+	quiet, _ := parseQuietHours(config.QuietHours)
+	return quiet.after(due), err
+}
+
+func validateSchedule(config *Config) error {
+	if _, err := parseSchedule(config.scheduleSpec()); err != nil {
+		return err
+	}
+	if _, err := parseQuietHours(config.QuietHours); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"0 0 30 2 *",
+		"@every 30s",
+		"@every soon",
+		"@fortnightly",
+	} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("parseSchedule(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2026-10-14 is a Wednesday.
+	after := time.Date(2026, 10, 14, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 14, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 7 * * *", time.Date(2026, 10, 15, 7, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * *", time.Date(2026, 10, 14, 13, 30, 0, 0, time.UTC)},
+		{"0 8 * * mon-fri", time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * sat,sun", time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches.
+		{"0 0 20 * mon", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2026, 10, 14, 11, 47, 30, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		sched, err := parseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := sched.next(after); !got.Equal(tt.want) {
+			t.Errorf("%q: next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 14, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		window string
+		at     time.Time
+		quiet  bool
+		after  time.Time
+	}{
+		{"12:00-13:00", day(11, 59), false, day(11, 59)},
+		{"12:00-13:00", day(12, 0), true, day(13, 0)},
+		{"12:00-13:00", day(13, 0), false, day(13, 0)},
+		{"23:00-07:00", day(23, 30), true, day(7, 0).AddDate(0, 0, 1)},
+		{"23:00-07:00", day(3, 0), true, day(7, 0)},
+		{"23:00-07:00", day(7, 0), false, day(7, 0)},
+		{"23:00-07:00", day(12, 0), false, day(12, 0)},
+	}
+	for _, tt := range tests {
+		q, err := parseQuietHours(tt.window)
+		if err != nil {
+			t.Fatalf("parseQuietHours(%q): %v", tt.window, err)
+		}
+		if got := q.contains(tt.at); got != tt.quiet {
+			t.Errorf("%s contains %v = %v, want %v", tt.window, tt.at, got, tt.quiet)
+		}
+		if got := q.after(tt.at); !got.Equal(tt.after) {
+			t.Errorf("%s after %v = %v, want %v", tt.window, tt.at, got, tt.after)
+		}
+	}
+
+	for _, window := range []string{"12:00", "12:00-12:00", "25:00-01:00", "noon-1pm"} {
+		if _, err := parseQuietHours(window); err == nil {
+			t.Errorf("parseQuietHours(%q) succeeded, want error", window)
+		}
+	}
+	if q, err := parseQuietHours(""); err != nil || q.contains(day(12, 0)) {
+		t.Errorf("empty quiet hours: %v, %v", q, err)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.Local)
+	config := &Config{ScheduleMinutes: 30}
+	source := Source{URL: "https://example.com"}
+
+	if got, _ := nextRun(config, source, time.Time{}, now); !got.Equal(now) {
+		t.Errorf("never run: next = %v, want now", got)
+	}
+	if got, _ := nextRun(config, source, now.Add(-10*time.Minute), now); !got.Equal(now.Add(20 * time.Minute)) {
+		t.Errorf("ran 10m ago: next = %v, want in 20m", got)
+	}
+	if got, _ := nextRun(config, source, now.Add(-24*time.Hour), now); !got.Equal(now) {
+		t.Errorf("missed run: next = %v, want now", got)
+	}
+
+	source.Schedule = "0 18 * * *"
+	if got, _ := nextRun(config, source, now, now); !got.Equal(now.Add(6 * time.Hour)) {
+		t.Errorf("source schedule: next = %v, want in 6h", got)
+	}
+
+	source.Schedule = "0 25 * * *"
+	got, err := nextRun(config, source, now, now)
+	if err == nil {
+		t.Error("invalid schedule: no error")
+	}
+	if !got.Equal(now.Add(defaultScheduleMinutes * time.Minute)) {
+		t.Errorf("invalid schedule: next = %v, want in %dm", got, defaultScheduleMinutes)
+	}
+
+	config.QuietHours = "11:00-14:00"
+	if got, _ := nextRun(config, Source{URL: source.URL}, time.Time{}, now); !got.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("quiet hours: next = %v, want in 2h", got)
+	}
+
+	config.QuietHours = ""
+	config.ScheduleJitterSeconds = 60
+	for range 20 {
+		got, _ := nextRun(config, Source{URL: source.URL}, now, now)
+		if got.Before(now.Add(30*time.Minute)) || !got.Before(now.Add(31*time.Minute)) {
+			t.Fatalf("jitter: next = %v, want within a minute after %v", got, now.Add(30*time.Minute))
+		}
+	}
+}
@@ -0,0 +1,788 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	stripmd "github.com/writeas/go-strip-markdown/v2"
+)
+
+const (
+	searchIndexVersion = 1
+	maxSearchResults   = 50
+	snippetTokens      = 30
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Document kinds in the search index.
+const (
+	docSummary = "summary"
+	docFocus   = "focus"
+)
+
+// searchIndex is an inverted index over the files in summaries/ and
+// focus/. It is stored in the data directory and brought up to date
+// before every search by re-indexing the files that changed.
+type searchIndex struct {
+	Version int                  `json:"version"`
+	NextID  int                  `json:"next_id"`
+	Docs    map[int]*indexedDoc  `json:"docs"`
+	Terms   map[string][]posting `json:"terms"`
+}
+
+type indexedDoc struct {
+	Path      string    `json:"path"`
+	Kind      string    `json:"kind"`
+	URL       string    `json:"url,omitempty"`
+	SourceID  string    `json:"source_id,omitempty"`
+	Generated time.Time `json:"generated"`
+	ModTime   time.Time `json:"mod_time"`
+	Size      int64     `json:"size"`
+	Length    int       `json:"length"`
+}
+
+// posting lists the token positions of a term in one document.
+type posting struct {
+	Doc       int   `json:"d"`
+	Positions []int `json:"p"`
+}
+
+// SearchFilter limits a search to some sources and a time range.
+// SourceIDs holds summary directory names; nil means all sources.
+type SearchFilter struct {
+	SourceIDs map[string]bool
+	Since     time.Time
+	Until     time.Time
+}
+
+type SearchResult struct {
+	Kind      string
+	URL       string
+	SourceID  string
+	Generated time.Time
+	Path      string
+	Score     float64
+	Snippet   []snippetPart
+
+	// highlights are the token positions of the matches.
+	highlights []int
+}
+
+// snippetPart is a piece of a result snippet; Match parts are highlighted.
+type snippetPart struct {
+	Text  string
+	Match bool
+}
+
+type token struct {
+	term       string
+	start, end int
+}
+
+func sourceID(url string) string {
+	hash := md5.Sum([]byte(url))
+	return hex.EncodeToString(hash[:])
+}
+
+// tokenize splits text into lowercased words with their byte offsets.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// searchText is the text of a stored file that gets indexed: the markdown
+// without formatting and without the summary header.
+func searchText(content string) string {
+	return stripmd.Strip(stripSummaryHeader(content))
+}
+
+func getSearchIndexPath() (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "search-index.json"), nil
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		Version: searchIndexVersion,
+		Docs:    make(map[int]*indexedDoc),
+		Terms:   make(map[string][]posting),
+	}
+}
+
+// loadSearchIndex reads the stored index and updates it. A missing,
+// unreadable or outdated index is rebuilt from scratch.
+func loadSearchIndex() (*searchIndex, error) {
+	path, err := getSearchIndexPath()
+	if err != nil {
+		return nil, err
+	}
+
+	index := newSearchIndex()
+	if data, err := os.ReadFile(path); err == nil {
+		var stored searchIndex
+		if json.Unmarshal(data, &stored) == nil && stored.Version == searchIndexVersion && stored.Docs != nil && stored.Terms != nil {
+			index = &stored
+		}
+	}
+
+	changed, err := index.update()
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		data, err := json.Marshal(index)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(path, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// indexFile is a file that should be in the index.
+type indexFile struct {
+	path string
+	kind string
+	info os.FileInfo
+}
+
+func searchableFiles() ([]indexFile, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	summariesDir, err := getSummariesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []indexFile
+	dirs, err := os.ReadDir(summariesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		archive, err := listArchive(filepath.Join(summariesDir, dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, entry := range archive {
+			if info, err := os.Stat(entry.Path); err == nil {
+				files = append(files, indexFile{path: entry.Path, kind: docSummary, info: info})
+			}
+		}
+	}
+
+	focusFiles, _ := filepath.Glob(filepath.Join(dataDir, "focus", "*.md"))
+	for _, path := range focusFiles {
+		if info, err := os.Stat(path); err == nil {
+			files = append(files, indexFile{path: path, kind: docFocus, info: info})
+		}
+	}
+
+	for i := range files {
+		rel, err := filepath.Rel(dataDir, files[i].path)
+		if err != nil {
+			return nil, err
+		}
+		files[i].path = rel
+	}
+	return files, nil
+}
+
+// update indexes new and modified files and drops deleted ones. It
+// reports whether the index changed.
+func (idx *searchIndex) update() (bool, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return false, err
+	}
+	files, err := searchableFiles()
+	if err != nil {
+		return false, err
+	}
+
+	byPath := make(map[string]int, len(idx.Docs))
+	for id, doc := range idx.Docs {
+		byPath[doc.Path] = id
+	}
+
+	stale := make(map[int]bool)
+	present := make(map[string]bool, len(files))
+	var added []indexFile
+	for _, file := range files {
+		present[file.path] = true
+		id, ok := byPath[file.path]
+		if !ok {
+			added = append(added, file)
+			continue
+		}
+		doc := idx.Docs[id]
+		if !doc.ModTime.Equal(file.info.ModTime()) || doc.Size != file.info.Size() {
+			stale[id] = true
+			added = append(added, file)
+		}
+	}
+	for path, id := range byPath {
+		if !present[path] {
+			stale[id] = true
+		}
+	}
+
+	if len(stale) > 0 {
+		idx.remove(stale)
+	}
+	for _, file := range added {
+		data, err := os.ReadFile(filepath.Join(dataDir, file.path))
+		if err != nil {
+			continue
+		}
+		idx.add(file, string(data))
+	}
+	return len(stale) > 0 || len(added) > 0, nil
+}
+
+func (idx *searchIndex) remove(ids map[int]bool) {
+	for id := range ids {
+		delete(idx.Docs, id)
+	}
+	for term, postings := range idx.Terms {
+		kept := postings[:0]
+		for _, p := range postings {
+			if !ids[p.Doc] {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(idx.Terms, term)
+		} else {
+			idx.Terms[term] = kept
+		}
+	}
+}
+
+func (idx *searchIndex) add(file indexFile, content string) {
+	doc := &indexedDoc{
+		Path:    file.path,
+		Kind:    file.kind,
+		ModTime: file.info.ModTime(),
+		Size:    file.info.Size(),
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file.path), ".md")
+	switch file.kind {
+	case docSummary:
+		doc.URL = parseSummaryURL(content)
+		doc.SourceID = filepath.Base(filepath.Dir(file.path))
+		doc.Generated, _ = time.Parse(time.RFC3339, name)
+	case docFocus:
+		if name != "combined" {
+			doc.SourceID = name
+		}
+		doc.Generated = parseGeneratedTime(content)
+		if doc.Generated.IsZero() {
+			doc.Generated = file.info.ModTime()
+		}
+	}
+
+	id := idx.NextID
+	idx.NextID++
+	idx.Docs[id] = doc
+
+	positions := make(map[string][]int)
+	tokens := tokenize(searchText(content))
+	for i, t := range tokens {
+		positions[t.term] = append(positions[t.term], i)
+	}
+	doc.Length = len(tokens)
+	for term, p := range positions {
+		idx.Terms[term] = append(idx.Terms[term], posting{Doc: id, Positions: p})
+	}
+}
+
+// queryWord is one word of a query term; a prefix word matches every
+// term starting with it.
+type queryWord struct {
+	text   string
+	prefix bool
+}
+
+// queryTerm is a single word or a phrase of consecutive words.
+type queryTerm []queryWord
+
+// queryError is returned by Search for a query that cannot be parsed.
+type queryError string
+
+func (e queryError) Error() string { return string(e) }
+
+// parseSearchQuery splits a query into terms. "Quoted words" form a
+// phrase and a trailing * makes a word a prefix. Words joined by
+// punctuation, like "e-mail", are matched as a phrase.
+func parseSearchQuery(query string) ([]queryTerm, error) {
+	var terms []queryTerm
+	rest := query
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		var chunk string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, queryError("unterminated quote in search query")
+			}
+			chunk, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			chunk, rest = rest[:end], rest[end:]
+		}
+
+		var term queryTerm
+		for _, field := range strings.Fields(chunk) {
+			tokens := tokenize(field)
+			for i, t := range tokens {
+				prefix := i == len(tokens)-1 && strings.HasSuffix(field, "*")
+				term = append(term, queryWord{text: t.term, prefix: prefix})
+			}
+		}
+		if len(term) > 0 {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, queryError("empty search query")
+	}
+	return terms, nil
+}
+
+// termHits are the start positions of a query term in one document.
+type termHits []int
+
+// match returns, per document, where term occurs.
+func (idx *searchIndex) match(term queryTerm, sortedTerms []string) map[int]termHits {
+	var words []map[int][]int
+	for _, word := range term {
+		positions := make(map[int][]int)
+		for _, t := range idx.expand(word, sortedTerms) {
+			for _, p := range idx.Terms[t] {
+				positions[p.Doc] = append(positions[p.Doc], p.Positions...)
+			}
+		}
+		if word.prefix {
+			for _, p := range positions {
+				sort.Ints(p)
+			}
+		}
+		words = append(words, positions)
+	}
+
+	hits := make(map[int]termHits)
+	for doc, starts := range words[0] {
+		var matched termHits
+		for _, start := range starts {
+			if phraseAt(words, doc, start) {
+				matched = append(matched, start)
+			}
+		}
+		if len(matched) > 0 {
+			hits[doc] = matched
+		}
+	}
+	return hits
+}
+
+func phraseAt(words []map[int][]int, doc, start int) bool {
+	for i := 1; i < len(words); i++ {
+		positions := words[i][doc]
+		j := sort.SearchInts(positions, start+i)
+		if j == len(positions) || positions[j] != start+i {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *searchIndex) expand(word queryWord, sortedTerms []string) []string {
+	if !word.prefix {
+		return []string{word.text}
+	}
+	var terms []string
+	for i := sort.SearchStrings(sortedTerms, word.text); i < len(sortedTerms) && strings.HasPrefix(sortedTerms[i], word.text); i++ {
+		terms = append(terms, sortedTerms[i])
+	}
+	return terms
+}
+
+func (f SearchFilter) matches(doc *indexedDoc) bool {
+	if f.SourceIDs != nil && !f.SourceIDs[doc.SourceID] {
+		return false
+	}
+	if !f.Since.IsZero() && doc.Generated.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !doc.Generated.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Search returns the documents containing every term of query, best
+// first. Scores are BM25, with phrases scored like single terms. An
+// invalid query is reported as a queryError.
+func Search(query string, filter SearchFilter) ([]SearchResult, error) {
+	terms, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := loadSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+	if len(idx.Docs) == 0 {
+		return nil, nil
+	}
+
+	sortedTerms := make([]string, 0, len(idx.Terms))
+	for term := range idx.Terms {
+		sortedTerms = append(sortedTerms, term)
+	}
+	sort.Strings(sortedTerms)
+
+	totalLength := 0
+	for _, doc := range idx.Docs {
+		totalLength += doc.Length
+	}
+	avgLength := float64(totalLength) / float64(len(idx.Docs))
+
+	scores := make(map[int]float64)
+	highlights := make(map[int][]int)
+	for i, term := range terms {
+		hits := idx.match(term, sortedTerms)
+		idf := math.Log(1 + (float64(len(idx.Docs))-float64(len(hits))+0.5)/(float64(len(hits))+0.5))
+
+		next := make(map[int]float64)
+		for doc, starts := range hits {
+			if _, ok := scores[doc]; !ok && i > 0 {
+				continue
+			}
+			if !filter.matches(idx.Docs[doc]) {
+				continue
+			}
+			tf := float64(len(starts))
+			norm := 1 - bm25B + bm25B*float64(idx.Docs[doc].Length)/avgLength
+			next[doc] = scores[doc] + idf*tf*(bm25K1+1)/(tf+bm25K1*norm)
+			for _, start := range starts {
+				for j := range term {
+					highlights[doc] = append(highlights[doc], start+j)
+				}
+			}
+		}
+		scores = next
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		doc := idx.Docs[id]
+		results = append(results, SearchResult{
+			Kind:       doc.Kind,
+			URL:        doc.URL,
+			SourceID:   doc.SourceID,
+			Generated:  doc.Generated,
+			Path:       doc.Path,
+			Score:      score,
+			highlights: highlights[id],
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Generated.After(results[j].Generated)
+	})
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Snippet = readSnippet(filepath.Join(dataDir, results[i].Path), results[i].highlights)
+	}
+	return results, nil
+}
+
+var spacePattern = regexp.MustCompile(`\s+`)
+
+// readSnippet cuts the window of snippetTokens tokens with the most
+// highlighted positions out of the file at path.
+func readSnippet(path string, marks []int) []snippetPart {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	text := searchText(string(data))
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	highlighted := make(map[int]bool)
+	var positions []int
+	for _, p := range marks {
+		if p < len(tokens) && !highlighted[p] {
+			highlighted[p] = true
+			positions = append(positions, p)
+		}
+	}
+	sort.Ints(positions)
+
+	start, best := 0, -1
+	for i, p := range positions {
+		count := sort.SearchInts(positions, p+snippetTokens) - i
+		if count > best {
+			start, best = max(0, p-snippetTokens/4), count
+		}
+	}
+	end := min(len(tokens), start+snippetTokens)
+
+	var parts []snippetPart
+	appendText := func(s string, match bool) {
+		if n := len(parts); n > 0 && parts[n-1].Match == match {
+			parts[n-1].Text += s
+			return
+		}
+		parts = append(parts, snippetPart{Text: s, Match: match})
+	}
+
+	if start > 0 {
+		appendText("... ", false)
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			appendText(spacePattern.ReplaceAllString(text[tokens[i-1].end:tokens[i].start], " "), false)
+		}
+		appendText(text[tokens[i].start:tokens[i].end], highlighted[i])
+	}
+	if end < len(tokens) {
+		appendText(" ...", false)
+	}
+	return parts
+}
+
+// searchSourceIDs resolves a --source value, a list number, name, URL or
+// tag, to summary directory names.
+func searchSourceIDs(config *Config, key string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	if n, err := strconv.Atoi(key); err == nil && n >= 1 && n <= len(config.Sources) {
+		ids[sourceID(config.Sources[n-1].URL)] = true
+		return ids, nil
+	}
+	for _, source := range config.Sources {
+		if source.URL == key || (source.Name != "" && strings.EqualFold(source.Name, key)) {
+			ids[sourceID(source.URL)] = true
+			continue
+		}
+		for _, tag := range source.Tags {
+			if strings.EqualFold(tag, key) {
+				ids[sourceID(source.URL)] = true
+			}
+		}
+	}
+	if len(ids) == 0 {
+		if !strings.HasPrefix(key, "http://") && !strings.HasPrefix(key, "https://") {
+			return nil, fmt.Errorf("source not found: %s", key)
+		}
+		// A removed source can still have summaries.
+		ids[sourceID(key)] = true
+	}
+	return ids, nil
+}
+
+func (r SearchResult) title(config *Config) string {
+	switch {
+	case r.Kind == docFocus && r.SourceID == "":
+		return "Combined focus summary"
+	case r.Kind == docFocus:
+		return "Focus: " + sourceTitle(config, sourceURLByID(config, r.SourceID))
+	}
+	return sourceTitle(config, r.URL)
+}
+
+func sourceURLByID(config *Config, id string) string {
+	if config != nil {
+		for _, source := range config.Sources {
+			if sourceID(source.URL) == id {
+				return source.URL
+			}
+		}
+	}
+	return id
+}
+
+// SearchSummaries shows search results in the pager, with matches in bold.
+func SearchSummaries(query string, filter SearchFilter) error {
+	results, err := Search(query, filter)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("No matching summaries found")
+		return nil
+	}
+
+	config, _ := LoadConfig()
+	bold := stdoutIsTerminal()
+	var b strings.Builder
+	fmt.Fprintf(&b, "Search: %s (%s)\n", query, resultCount(len(results)))
+	b.WriteString("═══════════════════════════════════════════════════════════════════\n\n")
+	for i, result := range results {
+		fmt.Fprintf(&b, "%d. %s  ·  %s\n", i+1, result.title(config), result.Generated.Local().Format("2006-01-02 15:04"))
+		if result.URL != "" {
+			fmt.Fprintf(&b, "   %s\n", result.URL)
+		}
+		var snippet strings.Builder
+		for _, part := range result.Snippet {
+			if part.Match && bold {
+				snippet.WriteString("\x1b[1m" + part.Text + "\x1b[0m")
+			} else {
+				snippet.WriteString(part.Text)
+			}
+		}
+		for _, line := range wrapText(snippet.String(), 75) {
+			fmt.Fprintf(&b, "   %s\n", line)
+		}
+		b.WriteString("\n")
+	}
+
+	return writeOutput([]byte(b.String()), "search.txt")
+}
+
+// SearchSummariesHTML shows search results in the browser.
+func SearchSummariesHTML(query string, filter SearchFilter) error {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return err
+	}
+	results, err := Search(query, filter)
+	if err != nil {
+		return err
+	}
+
+	config, _ := LoadConfig()
+	page := newPageData(config, "nub search")
+	page.Body, err = searchResultsHTML(config, query, results, func(r SearchResult) string {
+		if r.URL != "" {
+			return r.URL
+		}
+		return sourceURLByID(config, r.SourceID)
+	})
+	if err != nil {
+		return err
+	}
+	html, err := renderPage(page)
+	if err != nil {
+		return err
+	}
+	htmlFile := filepath.Join(dataDir, "search.html")
+	if err := os.WriteFile(htmlFile, []byte(html), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Opening search results in browser...\n")
+	fmt.Printf("File: %s\n", htmlFile)
+	return openInBrowser(htmlFile)
+}
+
+var searchResultsTemplate = template.Must(template.New("results").Funcs(pageFuncs).Parse(
+	`<div class="summary"><p class="meta">Search: {{.Query}} ({{.Count}})</p></div>
+{{range .Results}}<div class="summary result">
+<h3>{{if .Link}}<a href="{{.Link}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h3>
+<p class="meta">{{date .Generated}}</p>
+<p>{{range .Snippet}}{{if .Match}}<mark>{{.Text}}</mark>{{else}}{{.Text}}{{end}}{{end}}</p>
+</div>
+{{end}}`))
+
+type searchResultView struct {
+	Title     string
+	Link      string
+	Generated time.Time
+	Snippet   []snippetPart
+}
+
+// searchResultsHTML renders results with matches in <mark>. link returns
+// the target of a result's title, or "" for none.
+func searchResultsHTML(config *Config, query string, results []SearchResult, link func(SearchResult) string) (template.HTML, error) {
+	views := make([]searchResultView, 0, len(results))
+	for _, result := range results {
+		views = append(views, searchResultView{
+			Title:     result.title(config),
+			Link:      link(result),
+			Generated: result.Generated,
+			Snippet:   result.Snippet,
+		})
+	}
+	return renderFragment(searchResultsTemplate, map[string]any{
+		"Query":   query,
+		"Count":   resultCount(len(results)),
+		"Results": views,
+	})
+}
+
+func resultCount(n int) string {
+	if n == 1 {
+		return "1 result"
+	}
+	if n == maxSearchResults {
+		return fmt.Sprintf("top %d results", n)
+	}
+	return fmt.Sprintf("%d results", n)
+}
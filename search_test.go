@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []token
+	}{
+		{"", nil},
+		{"  ...  ", nil},
+		{"Go", []token{{"go", 0, 2}}},
+		{"Go 1.26 released!", []token{{"go", 0, 2}, {"1", 3, 4}, {"26", 5, 7}, {"released", 8, 16}}},
+		{"e-mail", []token{{"e", 0, 1}, {"mail", 2, 6}}},
+		{"Über straße", []token{{"über", 0, 5}, {"straße", 6, 13}}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// formatQuery writes terms back as a query, for comparing parse results.
+func formatQuery(terms []queryTerm) string {
+	var parts []string
+	for _, term := range terms {
+		var words []string
+		for _, word := range term {
+			if word.prefix {
+				words = append(words, word.text+"*")
+			} else {
+				words = append(words, word.text)
+			}
+		}
+		if len(words) > 1 {
+			parts = append(parts, `"`+strings.Join(words, " ")+`"`)
+		} else {
+			parts = append(parts, words[0])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"go", "go"},
+		{"  Go   Release ", "go release"},
+		{`"release notes"`, `"release notes"`},
+		{`go "release notes" now`, `go "release notes" now`},
+		{`"  release   notes  "`, `"release notes"`},
+		{"rel*", "rel*"},
+		{`"go rel*"`, `"go rel*"`},
+		{"e-mail", `"e mail"`},
+		{"e-ma*", `"e ma*"`},
+		{`"" go`, "go"},
+		{"go !!!", "go"},
+	}
+	for _, tt := range tests {
+		terms, err := parseSearchQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseSearchQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := formatQuery(terms); got != tt.want {
+			t.Errorf("parseSearchQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"", "   ", "!!!", `""`, "*", `"release notes`, `go "`} {
+		_, err := parseSearchQuery(query)
+		var invalid queryError
+		if !errors.As(err, &invalid) {
+			t.Errorf("parseSearchQuery(%q): err = %v, want a queryError", query, err)
+		}
+	}
+}
+
+// writeTestSummary archives a summary of url generated at the given time.
+func writeTestSummary(t *testing.T, url string, generated time.Time, body string) {
+	t.Helper()
+	summaryDir, err := getSummaryDir(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := fmt.Sprintf("# Summary for: %s\n\nGenerated: %s\n\n---\n\n%s\n", url, generated.Format(time.RFC3339), body)
+	path := filepath.Join(summaryDir, generated.Format(archiveTimeFormat)+".md")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func resultURLs(results []SearchResult) []string {
+	urls := []string{}
+	for _, result := range results {
+		urls = append(urls, result.URL)
+	}
+	return urls
+}
+
+func TestSearchPhrases(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	generated := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	writeTestSummary(t, "https://a.example", generated, "The Go release notes are out.")
+	writeTestSummary(t, "https://b.example", generated, "Go is great. Release notes follow later.")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"release", []string{"https://a.example", "https://b.example"}},
+		{`"release notes"`, []string{"https://a.example", "https://b.example"}},
+		{`"go release"`, []string{"https://a.example"}},
+		{`"go release notes"`, []string{"https://a.example"}},
+		{`"notes release"`, []string{}},
+		{`"great release"`, []string{"https://b.example"}},
+		{"rel*", []string{"https://a.example", "https://b.example"}},
+		{`"go rel*"`, []string{"https://a.example"}},
+		{`"go rel*" later`, []string{}},
+		{"great later", []string{"https://b.example"}},
+		{"missing", []string{}},
+	}
+	for _, tt := range tests {
+		results, err := Search(tt.query, SearchFilter{})
+		if err != nil {
+			t.Errorf("Search(%q): %v", tt.query, err)
+			continue
+		}
+		got := resultURLs(results)
+		if len(got) == 2 && got[0] > got[1] {
+			got[0], got[1] = got[1], got[0]
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	results, err := Search(`"go release"`, SearchFilter{})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search: %v, %v", results, err)
+	}
+	var marked []string
+	for _, part := range results[0].Snippet {
+		if part.Match {
+			marked = append(marked, part.Text)
+		}
+	}
+	if want := []string{"Go", "release"}; !reflect.DeepEqual(marked, want) {
+		t.Errorf("phrase highlights = %q, want %q", marked, want)
+	}
+
+	var invalid queryError
+	if _, err := Search(`"release`, SearchFilter{}); !errors.As(err, &invalid) {
+		t.Errorf("Search with an unterminated quote: err = %v, want a queryError", err)
+	}
+}
+
+func TestSearchFilter(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
+	a, b := "https://a.example", "https://b.example"
+	writeTestSummary(t, a, day(1), "kernel update one")
+	writeTestSummary(t, a, day(10), "kernel update two")
+	writeTestSummary(t, b, day(10), "kernel update three")
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		want   int
+	}{
+		{"none", SearchFilter{}, 3},
+		{"source", SearchFilter{SourceIDs: map[string]bool{sourceID(a): true}}, 2},
+		{"other source", SearchFilter{SourceIDs: map[string]bool{sourceID(b): true}}, 1},
+		{"unknown source", SearchFilter{SourceIDs: map[string]bool{sourceID("https://c.example"): true}}, 0},
+		{"since", SearchFilter{Since: day(5)}, 2},
+		{"since is inclusive", SearchFilter{Since: day(10)}, 2},
+		{"until", SearchFilter{Until: day(5)}, 1},
+		{"until is exclusive", SearchFilter{Until: day(10)}, 1},
+		{"range", SearchFilter{Since: day(2), Until: day(9)}, 0},
+		{"source and since", SearchFilter{SourceIDs: map[string]bool{sourceID(a): true}, Since: day(5)}, 1},
+	}
+	for _, tt := range tests {
+		results, err := Search("kernel", tt.filter)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(results) != tt.want {
+			t.Errorf("%s: %d results %v, want %d", tt.name, len(results), resultURLs(results), tt.want)
+		}
+		for _, result := range results {
+			if !tt.filter.matches(&indexedDoc{SourceID: result.SourceID, Generated: result.Generated}) {
+				t.Errorf("%s: result %s from %v does not match the filter", tt.name, result.URL, result.Generated)
+			}
+		}
+	}
+}
+
+func TestReadSnippet(t *testing.T) {
+	var words []string
+	for i := range 40 {
+		words = append(words, fmt.Sprintf("w%d", i))
+	}
+	long := strings.Join(words, " ")
+
+	tests := []struct {
+		name    string
+		content string
+		marks   []int
+		want    []snippetPart
+	}{
+		{"no marks", "alpha beta", nil, []snippetPart{{"alpha beta", false}}},
+		{"one mark", "alpha beta gamma", []int{1}, []snippetPart{{"alpha ", false}, {"beta", true}, {" gamma", false}}},
+		{"adjacent marks", "alpha beta gamma delta", []int{1, 2},
+			[]snippetPart{{"alpha ", false}, {"beta", true}, {" ", false}, {"gamma", true}, {" delta", false}}},
+		{"whitespace collapsed", "alpha\n\n  beta", []int{1}, []snippetPart{{"alpha ", false}, {"beta", true}}},
+		{"marks out of range", "alpha beta", []int{1, 1, 7}, []snippetPart{{"alpha ", false}, {"beta", true}}},
+		{"header stripped", "# Summary for: https://a.example\n\nGenerated: x\n\n---\n\nalpha beta\n", []int{0},
+			[]snippetPart{{"alpha", true}, {" beta", false}}},
+		{"window", long, []int{35}, []snippetPart{
+			{"... " + strings.Join(words[28:35], " ") + " ", false},
+			{"w35", true},
+			{" " + strings.Join(words[36:], " "), false},
+		}},
+		{"window end", long, []int{2}, []snippetPart{
+			{"w0 w1 ", false},
+			{"w2", true},
+			{" " + strings.Join(words[3:snippetTokens], " ") + " ...", false},
+		}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "summary.md")
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := readSnippet(path, tt.marks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: readSnippet = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
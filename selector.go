@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a small CSS selector subset: comma-separated groups of
+// descendant chains made of tag, #id and .class parts, e.g.
+// "article.post, main #content .body".
+type selector [][]compoundSelector
+
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+func parseSelector(value string) (selector, error) {
+	var groups selector
+	for _, group := range strings.Split(value, ",") {
+		var chain []compoundSelector
+		for _, part := range strings.Fields(group) {
+			compound, err := parseCompound(part)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, compound)
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("invalid selector %q", value)
+		}
+		groups = append(groups, chain)
+	}
+	return groups, nil
+}
+
+func parseCompound(part string) (compoundSelector, error) {
+	var compound compoundSelector
+	i := 0
+	for i < len(part) && part[i] != '#' && part[i] != '.' {
+		i++
+	}
+	compound.tag = strings.ToLower(part[:i])
+	if compound.tag == "*" {
+		compound.tag = ""
+	}
+
+	for i < len(part) {
+		kind := part[i]
+		j := i + 1
+		for j < len(part) && part[j] != '#' && part[j] != '.' {
+			j++
+		}
+		name := part[i+1 : j]
+		if name == "" {
+			return compound, fmt.Errorf("invalid selector part %q", part)
+		}
+		if kind == '#' {
+			compound.id = name
+		} else {
+			compound.classes = append(compound.classes, name)
+		}
+		i = j
+	}
+
+	for _, r := range compound.tag {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return compound, fmt.Errorf("unsupported selector %q (only tag, #id and .class are supported)", part)
+		}
+	}
+	return compound, nil
+}
+
+func (c compoundSelector) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if c.tag != "" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" && getAttr(n, "id") != c.id {
+		return false
+	}
+	classes := strings.Fields(getAttr(n, "class"))
+	for _, want := range c.classes {
+		found := false
+		for _, class := range classes {
+			if class == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (s selector) matches(n *html.Node) bool {
+	for _, chain := range s {
+		if !chain[len(chain)-1].matches(n) {
+			continue
+		}
+		i := len(chain) - 2
+		for p := n.Parent; p != nil && i >= 0; p = p.Parent {
+			if chain[i].matches(p) {
+				i--
+			}
+		}
+		if i < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// selectNodes returns the outermost nodes under root matching s.
+func (s selector) selectNodes(root *html.Node) []*html.Node {
+	var found []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if s.matches(n) {
+			found = append(found, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return found
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const refreshPollSeconds = 30
+
+var sourceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// serverTemplates are the parts of served pages around the summaries.
+var serverTemplates = template.Must(template.New("server").Funcs(pageFuncs).Parse(
+	`{{define "nav"}}<nav><a href="/">latest</a><form action="/search"><input type="search" name="q" placeholder="search" value="{{.}}"></form></nav>{{end}}
+{{define "archive"}}<div class="summary archive">
+<h2>Archive</h2>
+<ul>
+{{range .}}<li><a href="{{.Link}}">{{date .Generated}}</a></li>
+{{end}}</ul>
+</div>
+{{end}}`))
+
+type archiveLink struct {
+	Link      string
+	Generated time.Time
+}
+
+// refreshScript reloads the page when /changes reports that summaries
+// were written after the page was rendered.
+const refreshScript = `    <script>
+        (function () {
+            var version = "%s";
+            setInterval(function () {
+                fetch("/changes").then(function (r) { return r.text(); }).then(function (v) {
+                    if (v && v !== version) { location.reload(); }
+                }).catch(function () {});
+            }, %d000);
+        })();
+    </script>
+`
+
+// Serve serves the summaries view over HTTP until interrupted. Pages are
+// rendered from storage on every request, so they always show what the
+// daemon wrote last.
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", handleIndex)
+	mux.HandleFunc("GET /source/{id}", handleSource)
+	mux.HandleFunc("GET /source/{id}/{generated}", handleArchived)
+	mux.HandleFunc("GET /search", handleSearch)
+	mux.HandleFunc("GET /feed.atom", handleFeed)
+	mux.HandleFunc("GET /feed.rss", handleFeed)
+	mux.HandleFunc("GET /changes", handleChanges)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+	fmt.Printf("Serving summaries on http://%s/\n", displayAddr(addr))
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	filter, err := requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	order, err := parseSortOrder(r.URL.Query().Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summaries, err := LoadSummaries(filter)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	config, _ := LoadConfig()
+	sortSummaries(config, summaries, order)
+
+	page := newPageData(config, "nub")
+	if filter.Since.IsZero() && filter.AsOf.IsZero() {
+		page.Focus = pageFocus(config, order)
+	}
+	if len(summaries) == 0 {
+		page.Body = `<div class="summary"><p>No summarizations found</p></div>
+`
+	}
+	for _, summary := range summaries {
+		page.addSummary(servedSummary(config, summary))
+	}
+
+	writePage(w, page, "")
+}
+
+func handleSource(w http.ResponseWriter, r *http.Request) {
+	summaryDir, ok := sourceDir(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	archive, err := listArchive(summaryDir)
+	if err != nil || len(archive) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	latest, err := latestSummaryPath(summaryDir)
+	if err != nil || latest == "" {
+		latest = archive[0].Path
+	}
+	summary, err := readStoredSummary(latest)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	config, _ := LoadConfig()
+	page := newPageData(config, sourceTitle(config, summary.URL))
+	page.Summaries = []PageSummary{pageSummary(config, summary)}
+
+	var links []archiveLink
+	for _, entry := range archive {
+		name := strings.TrimSuffix(filepath.Base(entry.Path), ".md")
+		links = append(links, archiveLink{Link: "/source/" + r.PathValue("id") + "/" + name, Generated: entry.Generated})
+	}
+	page.Body, err = renderFragment(serverTemplates.Lookup("archive"), links)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	writePage(w, page, "")
+}
+
+func handleArchived(w http.ResponseWriter, r *http.Request) {
+	summaryDir, ok := sourceDir(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := r.PathValue("generated")
+	generated, err := time.Parse(time.RFC3339, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	path := filepath.Join(summaryDir, name+".md")
+	if !fileExists(path) {
+		http.NotFound(w, r)
+		return
+	}
+
+	summary, err := readStoredSummary(path)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	config, _ := LoadConfig()
+	page := newPageData(config, sourceTitle(config, summary.URL)+" - "+generated.Local().Format("2006-01-02 15:04"))
+	page.Summaries = []PageSummary{servedSummary(config, summary)}
+	writePage(w, page, "")
+}
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	summaryFilter, err := requestFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := SearchFilter{Since: summaryFilter.Since}
+	if !summaryFilter.AsOf.IsZero() {
+		filter.Since = summaryFilter.AsOf.AddDate(0, 0, -1)
+		filter.Until = summaryFilter.AsOf
+	}
+
+	config, _ := LoadConfig()
+	if source := r.URL.Query().Get("source"); source != "" && config != nil {
+		if filter.SourceIDs, err = searchSourceIDs(config, source); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := Search(query, filter)
+	var invalid queryError
+	if errors.As(err, &invalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	page := newPageData(config, "Search: "+query)
+	page.Body, err = searchResultsHTML(config, query, results, func(result SearchResult) string {
+		if result.Kind == docSummary {
+			return fmt.Sprintf("/source/%s/%s", result.SourceID, strings.TrimSuffix(filepath.Base(result.Path), ".md"))
+		}
+		return "/"
+	})
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writePage(w, page, query)
+}
+
+// handleFeed serves the digest feeds. They are generated on request so
+// that the Atom self link and the RSS channel link point at this server;
+// entries link to their sources. The server's address is taken from the
+// request's Host header and, behind a reverse proxy, X-Forwarded-Proto,
+// so those links are only as trustworthy as the proxy setting them.
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	config, err := LoadConfig()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	entries, err := feedEntries(config)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	site := scheme + "://" + r.Host + "/"
+
+	var data []byte
+	if strings.HasSuffix(r.URL.Path, ".rss") {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		data, err = rssFeedXML(entries, site)
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		data, err = atomFeedXML(entries, site+"feed.atom")
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Write(data)
+}
+
+// handleChanges returns a token that changes whenever a summary or focus
+// file is written, for the pages' auto-refresh.
+func handleChanges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprint(w, changeToken())
+}
+
+func changeToken() string {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return ""
+	}
+
+	var latest time.Time
+	for _, dir := range []string{"summaries", "focus"} {
+		filepath.WalkDir(filepath.Join(dataDir, dir), func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return strconv.FormatInt(latest.UnixNano(), 10)
+}
+
+// writePage writes a page with the navigation bar; query fills the
+// search box.
+func writePage(w http.ResponseWriter, page PageData, query string) {
+	nav, err := renderFragment(serverTemplates.Lookup("nav"), query)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	page.Nav = nav
+	page.Head = template.HTML(`    <link rel="alternate" type="application/atom+xml" title="nub digest" href="/feed.atom">
+    <link rel="alternate" type="application/rss+xml" title="nub digest" href="/feed.rss">
+` + fmt.Sprintf(refreshScript, changeToken(), refreshPollSeconds))
+
+	html, err := renderPage(page)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, html)
+}
+
+// serverError logs err and answers with a generic error, so clients do
+// not learn about paths or other details of the host.
+func serverError(w http.ResponseWriter, err error) {
+	log.Printf("Error: %v\n", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func requestFilter(r *http.Request) (SummaryFilter, error) {
+	var filter SummaryFilter
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := ParseSummaryDate(since)
+		if err != nil {
+			return filter, err
+		}
+		filter.Since = t
+	}
+	if date := r.URL.Query().Get("date"); date != "" {
+		t, err := ParseSummaryDate(date)
+		if err != nil {
+			return filter, err
+		}
+		filter.AsOf = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// sourceDir maps a source ID, the name of its summary directory, to the
+// directory.
+func sourceDir(id string) (string, bool) {
+	if !sourceIDPattern.MatchString(id) {
+		return "", false
+	}
+	summariesDir, err := getSummariesDir()
+	if err != nil {
+		return "", false
+	}
+	dir := filepath.Join(summariesDir, id)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
+
+func sourceTitle(config *Config, url string) string {
+	if config != nil {
+		for _, source := range config.Sources {
+			if source.URL == url {
+				return source.DisplayName()
+			}
+		}
+	}
+	return url
+}
+
+// servedSummary is a summary that links to its source's archive page.
+func servedSummary(config *Config, summary StoredSummary) PageSummary {
+	page := pageSummary(config, summary)
+	page.Link = "/source/" + page.ID
+	return page
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Source is one entry of Config.Sources. In config.json it is either a
+// plain URL string or an object carrying per-source settings.
+type Source struct {
+	URL      string            `json:"url"`
+	Name     string            `json:"name,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Prompt   string            `json:"prompt,omitempty"`
+	Model    string            `json:"model,omitempty"`
+	Enabled  *bool             `json:"enabled,omitempty"`
+	TTL      string            `json:"ttl,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Selector string            `json:"selector,omitempty"`
+	Schedule string            `json:"schedule,omitempty"`
+
+	FollowLinks int    `json:"follow_links,omitempty"`
+	FollowHosts string `json:"follow_hosts,omitempty"`
+}
+
+type sourceFields Source
+
+func (s *Source) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var url string
+		if err := json.Unmarshal(data, &url); err != nil {
+			return err
+		}
+		*s = Source{URL: url}
+		return nil
+	}
+
+	var fields sourceFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if fields.URL == "" {
+		return fmt.Errorf("source entry without url")
+	}
+	*s = Source(fields)
+	return nil
+}
+
+func (s Source) MarshalJSON() ([]byte, error) {
+	if s.isPlain() {
+		return json.Marshal(s.URL)
+	}
+	return json.Marshal(sourceFields(s))
+}
+
+func (s Source) isPlain() bool {
+	return s.Name == "" && len(s.Tags) == 0 && s.Prompt == "" && s.Model == "" &&
+		s.Enabled == nil && s.TTL == "" && len(s.Headers) == 0 && s.Selector == "" &&
+		s.Schedule == "" && s.FollowLinks == 0 && s.FollowHosts == ""
+}
+
+func (s Source) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+func (s Source) DisplayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.URL
+}
+
+func (s Source) validate() error {
+	if s.URL == "" {
+		return fmt.Errorf("source without url")
+	}
+	if s.TTL != "" {
+		if _, err := time.ParseDuration(s.TTL); err != nil {
+			return fmt.Errorf("source %s: invalid ttl %q: %v", s.DisplayName(), s.TTL, err)
+		}
+	}
+	if s.Selector != "" {
+		if _, err := parseSelector(s.Selector); err != nil {
+			return fmt.Errorf("source %s: %v", s.DisplayName(), err)
+		}
+	}
+	if s.Schedule != "" {
+		if _, err := parseSchedule(s.Schedule); err != nil {
+			return fmt.Errorf("source %s: %v", s.DisplayName(), err)
+		}
+	}
+	if s.FollowLinks < 0 || s.FollowLinks > maxFollowLinks {
+		return fmt.Errorf("source %s: follow_links must be between 0 and %d", s.DisplayName(), maxFollowLinks)
+	}
+	switch s.FollowHosts {
+	case "", followSameHost, followAnyHost:
+	default:
+		return fmt.Errorf("source %s: follow_hosts must be %q or %q", s.DisplayName(), followSameHost, followAnyHost)
+	}
+	return nil
+}
+
+func (s Source) cacheTTL(config *Config) time.Duration {
+	if ttl, err := time.ParseDuration(s.TTL); err == nil && ttl > 0 {
+		return ttl
+	}
+	return config.cacheTTL()
+}
+
+// forSource returns a copy of config with the source's prompt and model
+// overrides applied.
+func (c *Config) forSource(s Source) *Config {
+	sourceConfig := *c
+	if s.Prompt != "" {
+		sourceConfig.SummaryPrompt = s.Prompt
+	}
+	if s.Model != "" {
+		sourceConfig.LLMAPIModel = s.Model
+	}
+	return &sourceConfig
+}
+
+func (c *Config) enabledSources() []Source {
+	var sources []Source
+	for _, source := range c.Sources {
+		if source.IsEnabled() {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+func parseTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func parseHeader(value string) (string, string, error) {
+	key, val, found := strings.Cut(value, ":")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return "", "", fmt.Errorf("invalid header %q, use \"Name: value\"", value)
+	}
+	return key, strings.TrimSpace(val), nil
+}
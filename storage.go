@@ -3,9 +3,13 @@ package main
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,7 +19,33 @@ import (
 	stripmd "github.com/writeas/go-strip-markdown/v2"
 )
 
-func getSummaryFilePath(url string) (string, error) {
+const latestPointer = "latest"
+
+type StoredSummary struct {
+	URL       string
+	Generated time.Time
+	Path      string
+	Model     string
+	Content   string
+	Items     []SummaryItem
+}
+
+type SummaryFilter struct {
+	Since time.Time
+	AsOf  time.Time
+}
+
+func ParseSummaryDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339", value)
+}
+
+func getSummariesDir() (string, error) {
 	dataDir, err := GetDataDir()
 	if err != nil {
 		return "", err
@@ -26,21 +56,442 @@ func getSummaryFilePath(url string) (string, error) {
 		return "", err
 	}
 
+	if err := migrateLegacySummaries(summariesDir); err != nil {
+		return "", err
+	}
+	return summariesDir, nil
+}
+
+func getSummaryDir(url string) (string, error) {
+	summariesDir, err := getSummariesDir()
+	if err != nil {
+		return "", err
+	}
+
 	hash := md5.Sum([]byte(url))
-	filename := hex.EncodeToString(hash[:]) + ".md"
-	return filepath.Join(summariesDir, filename), nil
+	summaryDir := filepath.Join(summariesDir, hex.EncodeToString(hash[:]))
+	if err := os.MkdirAll(summaryDir, 0755); err != nil {
+		return "", err
+	}
+	return summaryDir, nil
 }
 
-func StoreSummarization(url, summary string) error {
-	summaryPath, err := getSummaryFilePath(url)
+// migrateLegacySummaries moves summaries written before the archive
+// layout (summaries/<md5>.md) into summaries/<md5>/<timestamp>.md.
+func migrateLegacySummaries(summariesDir string) error {
+	entries, err := os.ReadDir(summariesDir)
 	if err != nil {
 		return err
 	}
 
-	timestamp := time.Now().Format(time.RFC3339)
-	content := fmt.Sprintf("# Summary for: %s\n\nGenerated: %s\n\n---\n\n%s\n", url, timestamp, summary)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+
+		legacyPath := filepath.Join(summariesDir, entry.Name())
+		data, err := os.ReadFile(legacyPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		generated := parseGeneratedTime(string(data))
+		if generated.IsZero() {
+			if info, err := entry.Info(); err == nil {
+				generated = info.ModTime()
+			}
+		}
+
+		summaryDir := filepath.Join(summariesDir, strings.TrimSuffix(entry.Name(), ".md"))
+		if err := os.MkdirAll(summaryDir, 0755); err != nil {
+			return err
+		}
+
+		name := archiveFileName(generated)
+		if err := os.Rename(legacyPath, filepath.Join(summaryDir, name)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(summaryDir, latestPointer)); os.IsNotExist(err) {
+			if err := writeFileAtomic(filepath.Join(summaryDir, latestPointer), []byte(name), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// archiveTimeFormat names archived summaries. It is RFC 3339 with a fixed
+// number of fractional digits, so names sort by time; summaries stored
+// before it have names without the fraction.
+const archiveTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func archiveFileName(t time.Time) string {
+	return t.UTC().Format(archiveTimeFormat) + ".md"
+}
+
+func parseGeneratedTime(content string) time.Time {
+	for _, line := range strings.Split(content, "\n") {
+		if value, found := strings.CutPrefix(line, "Generated: "); found {
+			if t, err := time.Parse(time.RFC3339, strings.TrimSpace(value)); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// parseSummaryModel returns the model named in a summary's header, or ""
+// for summaries stored before the model was recorded.
+func parseSummaryModel(content string) string {
+	header, _, _ := strings.Cut(content, "\n---\n")
+	for _, line := range strings.Split(header, "\n") {
+		if value, found := strings.CutPrefix(line, "Model: "); found {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func parseSummaryURL(content string) string {
+	firstLine, _, _ := strings.Cut(content, "\n")
+	url, _ := strings.CutPrefix(firstLine, "# Summary for: ")
+	return strings.TrimSpace(url)
+}
+
+// stripSummaryHeader returns a stored summary or focus summary without
+// the title and "Generated:" lines written in front of it.
+func stripSummaryHeader(content string) string {
+	if strings.HasPrefix(content, "# Summary for: ") || strings.HasPrefix(content, "# Combined Focus Summary") {
+		if _, body, ok := strings.Cut(content, "\n---\n"); ok {
+			return strings.TrimLeft(body, "\n")
+		}
+	}
+	return content
+}
+
+// StoreSummarization archives a summary and makes it the latest one. A
+// structured summary, if given, is written next to the markdown with the
+// same timestamp and a .json extension.
+func StoreSummarization(url, model, summary string, structured *StructuredSummary) error {
+	summaryDir, err := getSummaryDir(url)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	timestamp := now.Format(time.RFC3339)
+	header := fmt.Sprintf("# Summary for: %s\n\nGenerated: %s\n\n", url, timestamp)
+	if model != "" {
+		header += fmt.Sprintf("Model: %s\n\n", model)
+	}
+	content := header + "---\n\n" + summary + "\n"
+
+	// Never overwrite an earlier summary, e.g. of a run started at the
+	// same moment from the command line and the daemon: the name is
+	// claimed by creating the file, and taken names move on a microsecond.
+	var name string
+	for {
+		name = archiveFileName(now)
+		err := createFileExclusive(filepath.Join(summaryDir, name), []byte(content), 0644)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		now = now.Add(time.Microsecond)
+	}
+	if structured != nil {
+		structured.Generated = now.UTC().Truncate(time.Microsecond)
+		data, err := json.MarshalIndent(structured, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(summaryDir, structuredFileName(name)), data, 0644); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filepath.Join(summaryDir, latestPointer), []byte(name), 0644)
+}
+
+func listArchive(summaryDir string) ([]StoredSummary, error) {
+	entries, err := os.ReadDir(summaryDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive []StoredSummary
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".md" {
+			continue
+		}
+		generated, err := time.Parse(time.RFC3339, strings.TrimSuffix(name, ".md"))
+		if err != nil {
+			continue
+		}
+		archive = append(archive, StoredSummary{
+			Generated: generated,
+			Path:      filepath.Join(summaryDir, name),
+		})
+	}
+
+	sort.Slice(archive, func(i, j int) bool {
+		return archive[i].Generated.After(archive[j].Generated)
+	})
+	return archive, nil
+}
+
+func latestSummaryPath(summaryDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(summaryDir, latestPointer))
+	if err == nil {
+		path := filepath.Join(summaryDir, filepath.Base(strings.TrimSpace(string(data))))
+		if fileExists(path) {
+			return path, nil
+		}
+	}
+
+	archive, err := listArchive(summaryDir)
+	if err != nil {
+		return "", err
+	}
+	if len(archive) == 0 {
+		return "", nil
+	}
+	return archive[0].Path, nil
+}
+
+func readStoredSummary(path string) (StoredSummary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return StoredSummary{}, err
+	}
+
+	content := string(data)
+	generated, err := time.Parse(time.RFC3339, strings.TrimSuffix(filepath.Base(path), ".md"))
+	if err != nil {
+		generated = parseGeneratedTime(content)
+	}
+
+	return StoredSummary{
+		URL:       parseSummaryURL(content),
+		Generated: generated,
+		Path:      path,
+		Model:     parseSummaryModel(content),
+		Content:   content,
+		Items:     readStructuredItems(path),
+	}, nil
+}
+
+func structuredFileName(name string) string {
+	return strings.TrimSuffix(name, ".md") + ".json"
+}
+
+// readStructuredItems returns the items stored next to the summary at
+// path, or nil for summaries generated without structured mode.
+func readStructuredItems(path string) []SummaryItem {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), structuredFileName(filepath.Base(path))))
+	if err != nil {
+		return nil
+	}
+	var structured StructuredSummary
+	if err := json.Unmarshal(data, &structured); err != nil {
+		return nil
+	}
+	return structured.Items
+}
+
+func selectSummaries(summaryDir string, filter SummaryFilter) ([]string, error) {
+	if filter.Since.IsZero() && filter.AsOf.IsZero() {
+		path, err := latestSummaryPath(summaryDir)
+		if err != nil || path == "" {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	archive, err := listArchive(summaryDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range archive {
+		if !filter.AsOf.IsZero() {
+			if entry.Generated.Before(filter.AsOf) {
+				return []string{entry.Path}, nil
+			}
+			continue
+		}
+		if !entry.Generated.Before(filter.Since) {
+			paths = append(paths, entry.Path)
+		}
+	}
+	return paths, nil
+}
+
+func LoadSummaries(filter SummaryFilter) ([]StoredSummary, error) {
+	summariesDir, err := getSummariesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(summariesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []StoredSummary
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		paths, err := selectSummaries(filepath.Join(summariesDir, entry.Name()), filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			summary, err := readStoredSummary(path)
+			if err != nil {
+				continue
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries, nil
+}
+
+func GetStoredSummary(url string) (string, error) {
+	summaryDir, err := getSummaryDir(url)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := latestSummaryPath(summaryDir)
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, body, found := strings.Cut(string(data), "\n---\n\n")
+	if !found {
+		return "", nil
+	}
+	return strings.TrimSpace(body), nil
+}
+
+type SourceState struct {
+	URL          string    `json:"url"`
+	ContentHash  string    `json:"content_hash"`
+	SummarizedAt time.Time `json:"summarized_at"`
+}
+
+func getStateFilePath(url string) (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	stateDir := filepath.Join(dataDir, "state")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return "", err
+	}
+
+	hash := md5.Sum([]byte(url))
+	filename := hex.EncodeToString(hash[:]) + ".json"
+	return filepath.Join(stateDir, filename), nil
+}
+
+func GetSourceState(url string) (*SourceState, error) {
+	statePath, err := getStateFilePath(url)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state SourceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func StoreSourceState(url string, state *SourceState) error {
+	statePath, err := getStateFilePath(url)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(statePath, data, 0644)
+}
+
+func getLastRunsPath() (string, error) {
+	dataDir, err := GetDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "schedule.json"), nil
+}
+
+// GetLastRuns returns when the daemon last ran each source, keyed by URL.
+func GetLastRuns() (map[string]time.Time, error) {
+	runs := make(map[string]time.Time)
+
+	path, err := getLastRunsPath()
+	if err != nil {
+		return runs, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return runs, nil
+	}
+	if err != nil {
+		return runs, err
+	}
 
-	return os.WriteFile(summaryPath, []byte(content), 0644)
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return make(map[string]time.Time), nil
+	}
+	return runs, nil
+}
+
+func StoreLastRuns(runs map[string]time.Time) error {
+	path, err := getLastRunsPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
 }
 
 func getFocusFilePath(url string) (string, error) {
@@ -64,7 +515,7 @@ func StoreFocusedContent(url, focused string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(focusPath, []byte(focused), 0644)
+	return writeFileAtomic(focusPath, []byte(focused), 0644)
 }
 
 func StoreCombinedFocusedContent(focused string) error {
@@ -81,7 +532,7 @@ func StoreCombinedFocusedContent(focused string) error {
 	focusPath := filepath.Join(focusDir, "combined.md")
 	timestamp := time.Now().Format(time.RFC3339)
 	content := fmt.Sprintf("# Combined Focus Summary\n\nGenerated: %s\n\n---\n\n%s\n", timestamp, focused)
-	return os.WriteFile(focusPath, []byte(content), 0644)
+	return writeFileAtomic(focusPath, []byte(content), 0644)
 }
 
 func GetFocusedContent(url string) (string, error) {
@@ -100,274 +551,94 @@ func GetFocusedContent(url string) (string, error) {
 	return string(data), nil
 }
 
-func ShowSummarizations() error {
-	dataDir, err := GetDataDir()
+func ShowSummarizations(filter SummaryFilter, order, format string) error {
+	summaries, err := LoadSummaries(filter)
 	if err != nil {
 		return err
 	}
 
-	summariesDir := filepath.Join(dataDir, "summaries")
-	if _, err := os.Stat(summariesDir); os.IsNotExist(err) {
-		fmt.Println("No summarizations found")
-		return nil
-	}
-
-	files, err := os.ReadDir(summariesDir)
-	if err != nil {
-		return err
+	config, _ := LoadConfig()
+	sortSummaries(config, summaries, order)
+	if format == formatJSON || format == formatNDJSON {
+		data, err := encodeRecords(summaryRecords(config, summaries), format)
+		if err != nil {
+			return err
+		}
+		return writeOutput(data, "view.json")
 	}
 
-	if len(files) == 0 {
+	if len(summaries) == 0 {
 		fmt.Println("No summarizations found")
 		return nil
 	}
 
-	tempFile := filepath.Join(dataDir, "view.md")
-	var content string
-
-	config, err := LoadConfig()
-	if err == nil && config.FocusTopics != "" {
-		content += fmt.Sprintf("═══════════════════════════════════════════════════════════════════\n")
-		content += fmt.Sprintf("  FOCUS: %s\n", config.FocusTopics)
-		content += fmt.Sprintf("═══════════════════════════════════════════════════════════════════\n\n")
-
-		focusDir := filepath.Join(dataDir, "focus")
-		if focusFiles, err := os.ReadDir(focusDir); err == nil {
-			for _, focusFile := range focusFiles {
-				if filepath.Ext(focusFile.Name()) != ".md" {
-					continue
-				}
-				focusPath := filepath.Join(focusDir, focusFile.Name())
-				focusContent, err := os.ReadFile(focusPath)
-				if err == nil {
-					content += markdownToPlainText(string(focusContent)) + "\n\n"
-				}
-			}
-		}
-		content += "───────────────────────────────────────────────────────────────────\n\n"
+	render := markdownToPlainText
+	separator := "───────────────────────────────────────────────────────────────────\n\n"
+	if format == formatMarkdown {
+		render = strings.TrimSpace
+		separator = "---\n\n"
 	}
 
-	for _, file := range files {
-		if filepath.Ext(file.Name()) != ".md" {
-			continue
-		}
+	var content string
 
-		filePath := filepath.Join(summariesDir, file.Name())
-		fileContent, err := os.ReadFile(filePath)
-		if err != nil {
-			continue
+	if config != nil && config.FocusTopics != "" {
+		if format == formatMarkdown {
+			content += fmt.Sprintf("# Focus: %s\n\n", config.FocusTopics)
+		} else {
+			content += fmt.Sprintf("═══════════════════════════════════════════════════════════════════\n")
+			content += fmt.Sprintf("  FOCUS: %s\n", config.FocusTopics)
+			content += fmt.Sprintf("═══════════════════════════════════════════════════════════════════\n\n")
 		}
 
-		content += markdownToPlainText(string(fileContent)) + "\n\n"
-		content += "───────────────────────────────────────────────────────────────────\n\n"
-	}
-
-	if err := os.WriteFile(tempFile, []byte(content), 0644); err != nil {
-		return err
+		for _, focusContent := range focusContents(config, order) {
+			content += render(focusContent) + "\n\n"
+		}
+		content += separator
 	}
 
-	pager := "less"
-	if os.Getenv("PAGER") != "" {
-		pager = os.Getenv("PAGER")
+	for i, summary := range summaries {
+		if format == formatMarkdown && i > 0 {
+			content += separator
+		}
+		content += render(summary.Content) + "\n\n"
+		if format != formatMarkdown {
+			content += separator
+		}
 	}
 
-	exec := &execCmd{name: pager, args: []string{tempFile}}
-	return exec.runWait()
+	return writeOutput([]byte(content), "view.md")
 }
 
-func ShowSummarizationsHTML() error {
+func ShowSummarizationsHTML(filter SummaryFilter, order string) error {
 	dataDir, err := GetDataDir()
 	if err != nil {
 		return err
 	}
 
-	summariesDir := filepath.Join(dataDir, "summaries")
-	if _, err := os.Stat(summariesDir); os.IsNotExist(err) {
-		fmt.Println("No summarizations found")
-		return nil
-	}
-
-	files, err := os.ReadDir(summariesDir)
+	summaries, err := LoadSummaries(filter)
 	if err != nil {
 		return err
 	}
 
-	if len(files) == 0 {
+	if len(summaries) == 0 {
 		fmt.Println("No summarizations found")
 		return nil
 	}
 
 	htmlFile := filepath.Join(dataDir, "view.html")
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>nub</title>
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body {
-            font-family: Verdana, Geneva, sans-serif;
-            font-size: 10pt;
-            color: #000;
-            background: #f6f6ef;
-            padding: 8px;
-        }
-        .container {
-            max-width: 1200px;
-            margin: 0 auto;
-            background: #f6f6ef;
-        }
-        header {
-            background: #dc94ba;
-            padding: 2px 4px;
-            margin-bottom: 10px;
-        }
-        h1 {
-            font-size: 11pt;
-            font-weight: bold;
-            color: #000;
-            display: inline;
-        }
-        .summary {
-            background: #fff;
-            padding: 8px;
-            margin-bottom: 8px;
-            border: 1px solid #e0e0e0;
-        }
-        h2 {
-            font-size: 11pt;
-            font-weight: bold;
-            margin: 8px 0 4px 0;
-            color: #000;
-        }
-        h3 {
-            font-size: 10pt;
-            font-weight: bold;
-            margin: 6px 0 3px 0;
-            color: #000;
-        }
-        p {
-            margin: 6px 0;
-            line-height: 1.4;
-        }
-        a {
-            color: #000;
-            text-decoration: underline;
-        }
-        a:visited { color: #828282; }
-        code {
-            font-family: monospace;
-            font-size: 9pt;
-            background: #f0f0f0;
-            padding: 1px 3px;
-        }
-        pre {
-            background: #f5f5f5;
-            border: 1px solid #ddd;
-            padding: 8px;
-            overflow-x: auto;
-            margin: 8px 0;
-            font-size: 9pt;
-            line-height: 1.3;
-        }
-        pre code {
-            background: none;
-            padding: 0;
-        }
-        ul, ol {
-            margin: 6px 0 6px 20px;
-        }
-        li {
-            margin: 2px 0;
-            line-height: 1.4;
-        }
-        blockquote {
-            border-left: 2px solid #ccc;
-            padding-left: 10px;
-            margin: 6px 0;
-            color: #555;
-        }
-        hr {
-            border: none;
-            border-top: 1px solid #ccc;
-            margin: 10px 0;
-        }
-        strong { font-weight: bold; }
-        em { font-style: italic; }
-        .meta {
-            font-size: 8pt;
-            color: #828282;
-            margin-bottom: 4px;
-        }
-        .focus-section {
-            background: #fce4f0;
-            padding: 10px;
-            margin-bottom: 10px;
-            border: 1px solid #dc94ba;
-        }
-        .focus-section h2 {
-            color: #c2608a;
-            margin-top: 0;
-        }
-        @media (max-width: 700px) {
-            body { padding: 4px; font-size: 9pt; }
-            .summary { padding: 6px; }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <header>
-            <h1>nub</h1>
-        </header>
-`
-
-	config, err := LoadConfig()
-	if err == nil && config.FocusTopics != "" {
-		html += fmt.Sprintf(`        <div class="focus-section">
-            <h2>Focus: %s</h2>
-`, config.FocusTopics)
-
-		focusDir := filepath.Join(dataDir, "focus")
-		if focusFiles, err := os.ReadDir(focusDir); err == nil {
-			for _, focusFile := range focusFiles {
-				if filepath.Ext(focusFile.Name()) != ".md" {
-					continue
-				}
-				focusPath := filepath.Join(focusDir, focusFile.Name())
-				focusContent, err := os.ReadFile(focusPath)
-				if err == nil {
-					html += markdownToHTML(string(focusContent))
-				}
-			}
-		}
 
-		html += `        </div>
-`
+	config, _ := LoadConfig()
+	sortSummaries(config, summaries, order)
+	page := newPageData(config, "nub")
+	page.Focus = pageFocus(config, order)
+	for _, summary := range summaries {
+		page.addSummary(pageSummary(config, summary))
 	}
-
-	for _, file := range files {
-		if filepath.Ext(file.Name()) != ".md" {
-			continue
-		}
-
-		filePath := filepath.Join(summariesDir, file.Name())
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			continue
-		}
-
-		html += `<div class="summary">
-` + markdownToHTML(string(content)) + `
-</div>
-`
+	html, err := renderPage(page)
+	if err != nil {
+		return err
 	}
 
-	html += `    </div>
-</body>
-</html>`
-
 	if err := os.WriteFile(htmlFile, []byte(html), 0644); err != nil {
 		return err
 	}
@@ -387,7 +658,7 @@ func markdownToHTML(md string) string {
 	opts := html.RendererOptions{Flags: htmlFlags}
 	renderer := html.NewRenderer(opts)
 
-	return string(markdown.Render(doc, renderer))
+	return sanitizeHTML(string(markdown.Render(doc, renderer)))
 }
 
 func markdownToPlainText(md string) string {
@@ -463,6 +734,50 @@ func fileExists(path string) bool {
 	return err == nil
 }
 
+// writeFileAtomic writes to a temporary file and renames it into place,
+// so an interrupted write never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// createFileExclusive writes data to path like writeFileAtomic, but fails
+// with fs.ErrExist instead of replacing a file that is already there.
+func createFileExclusive(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Link(tmp.Name(), path)
+}
+
 func ClearAllData() error {
 	dataDir, err := GetDataDir()
 	if err != nil {
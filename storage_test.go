@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCreateFileExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	if err := createFileExclusive(path, []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := createFileExclusive(path, []byte("second"), 0644); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("second create: err = %v, want fs.ErrExist", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first" {
+		t.Errorf("content = %q, want %q", data, "first")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestStoreSummarizationConcurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const runs = 20
+	url := "https://example.com"
+
+	var wg sync.WaitGroup
+	for range runs {
+		wg.Go(func() {
+			if err := StoreSummarization(url, "model", "summary", nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	summaryDir, err := getSummaryDir(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := listArchive(summaryDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive) != runs {
+		t.Errorf("archived %d summaries, want %d", len(archive), runs)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minImportance     = 1
+	maxImportance     = 5
+	defaultImportance = 3
+	maxItemTopics     = 5
+)
+
+// SummaryItem is one story of a structured summary.
+type SummaryItem struct {
+	Headline   string   `json:"headline"`
+	Summary    string   `json:"summary"`
+	Link       string   `json:"link,omitempty"`
+	Topics     []string `json:"topics,omitempty"`
+	Importance int      `json:"importance"`
+}
+
+// StructuredSummary is stored as <timestamp>.json next to the markdown
+// summary of the same run.
+type StructuredSummary struct {
+	URL       string        `json:"url"`
+	Generated time.Time     `json:"generated"`
+	Model     string        `json:"model,omitempty"`
+	Items     []SummaryItem `json:"items"`
+}
+
+const structuredFormat = `Respond with JSON only, no markdown and no code fences, using this schema:
+{"items": [{"headline": "short headline", "summary": "one sentence", "link": "URL of the story, if the content has one", "topics": ["lowercase topic"], "importance": 3}]}
+List one item per story, most important first. "importance" is an integer from 1 (minor) to 5 (major news). Use at most 5 topics per item.`
+
+// SummarizeStructured summarizes text into items. A response that is not
+// valid JSON is first repaired locally, then handed back to the model once
+// to be fixed.
+func SummarizeStructured(ctx context.Context, config *Config, provider LLMProvider, text, sourceURL string) ([]SummaryItem, error) {
+	response, err := summarize(ctx, config, provider, text, sourceURL, structuredFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := parseSummaryItems(response, sourceURL)
+	if err == nil {
+		return items, nil
+	}
+
+	prompt := fmt.Sprintf(`The following response was supposed to be JSON but could not be used (%v). Return the same content as valid JSON.
+
+%s
+
+Response:
+%s`, err, structuredFormat, response)
+	repaired, rerr := provider.Complete(ctx, prompt)
+	if rerr != nil {
+		return nil, fmt.Errorf("invalid structured summary: %v", err)
+	}
+	items, err = parseSummaryItems(repaired, sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid structured summary: %v", err)
+	}
+	return items, nil
+}
+
+var trailingCommaPattern = regexp.MustCompile(`,\s*([}\]])`)
+
+// rawSummaryItem accepts the shapes models commonly produce: topics as a
+// comma-separated string, importance as a string or float, "title" or
+// "url" instead of "headline" or "link".
+type rawSummaryItem struct {
+	Headline   string          `json:"headline"`
+	Title      string          `json:"title"`
+	Summary    string          `json:"summary"`
+	Link       string          `json:"link"`
+	URL        string          `json:"url"`
+	Topics     json.RawMessage `json:"topics"`
+	Importance json.RawMessage `json:"importance"`
+}
+
+func parseSummaryItems(response, sourceURL string) ([]SummaryItem, error) {
+	body := extractJSON(response)
+	if body == "" {
+		return nil, fmt.Errorf("no JSON found")
+	}
+	raw, err := decodeSummaryItems(body)
+	if err != nil {
+		// Trailing commas are the most common slip; retry without them.
+		var rerr error
+		if raw, rerr = decodeSummaryItems(trailingCommaPattern.ReplaceAllString(body, "$1")); rerr != nil {
+			return nil, err
+		}
+	}
+
+	var items []SummaryItem
+	for _, r := range raw {
+		if item, ok := r.normalize(sourceURL); ok {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no items")
+	}
+	return items, nil
+}
+
+// decodeSummaryItems accepts {"items": [...]} as asked for, or a bare
+// array of items.
+func decodeSummaryItems(body string) ([]rawSummaryItem, error) {
+	if strings.HasPrefix(body, "[") {
+		var raw []rawSummaryItem
+		err := json.Unmarshal([]byte(body), &raw)
+		return raw, err
+	}
+	var wrapper struct {
+		Items []rawSummaryItem `json:"items"`
+	}
+	err := json.Unmarshal([]byte(body), &wrapper)
+	return wrapper.Items, err
+}
+
+// extractJSON returns the outermost JSON object or array in s, skipping
+// code fences and any prose around it.
+func extractJSON(s string) string {
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := byte('}')
+	if s[start] == '[' {
+		closer = ']'
+	}
+	end := strings.LastIndexByte(s, closer)
+	if end < start {
+		return ""
+	}
+	return s[start : end+1]
+}
+
+func (r rawSummaryItem) normalize(sourceURL string) (SummaryItem, bool) {
+	item := SummaryItem{
+		Headline:   cleanItemText(r.Headline),
+		Summary:    cleanItemText(r.Summary),
+		Link:       resolveItemLink(sourceURL, r.Link),
+		Topics:     parseItemTopics(r.Topics),
+		Importance: parseImportance(r.Importance),
+	}
+	if item.Headline == "" {
+		item.Headline = cleanItemText(r.Title)
+	}
+	if item.Link == "" {
+		item.Link = resolveItemLink(sourceURL, r.URL)
+	}
+	if item.Headline == "" {
+		item.Headline, item.Summary = item.Summary, ""
+	}
+	return item, item.Headline != ""
+}
+
+func cleanItemText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// resolveItemLink keeps http(s) links only, resolving relative ones
+// against the source.
+func resolveItemLink(sourceURL, link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	if base, err := url.Parse(sourceURL); err == nil {
+		ref = base.ResolveReference(ref)
+	}
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return ""
+	}
+	return ref.String()
+}
+
+func parseItemTopics(raw json.RawMessage) []string {
+	var topics []string
+	if err := json.Unmarshal(raw, &topics); err != nil {
+		var joined string
+		if json.Unmarshal(raw, &joined) != nil {
+			return nil
+		}
+		topics = strings.Split(joined, ",")
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, topic := range topics {
+		topic = strings.ToLower(cleanItemText(topic))
+		if topic == "" || seen[topic] {
+			continue
+		}
+		seen[topic] = true
+		result = append(result, topic)
+		if len(result) == maxItemTopics {
+			break
+		}
+	}
+	return result
+}
+
+func parseImportance(raw json.RawMessage) int {
+	value := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultImportance
+	}
+	return max(minImportance, min(maxImportance, int(f+0.5)))
+}
+
+// markdownLinkEscaper percent-encodes the characters that would end a
+// markdown link destination early.
+var markdownLinkEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+
+// itemsToMarkdown renders items as the markdown summary shown by --show
+// and --show-html.
+func itemsToMarkdown(items []SummaryItem) string {
+	var b strings.Builder
+	for i, item := range items {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "### %s\n\n", item.Headline)
+		if item.Summary != "" {
+			fmt.Fprintf(&b, "%s\n\n", item.Summary)
+		}
+
+		meta := []string{fmt.Sprintf("Importance: %d/%d", item.Importance, maxImportance)}
+		if len(item.Topics) > 0 {
+			meta = append(meta, "Topics: "+strings.Join(item.Topics, ", "))
+		}
+		if item.Link != "" {
+			meta = append(meta, fmt.Sprintf("[Link](%s)", markdownLinkEscaper.Replace(item.Link)))
+		}
+		fmt.Fprintf(&b, "*%s*\n", strings.Join(meta, " · "))
+	}
+	return b.String()
+}
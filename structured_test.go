@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"items": []}`, `{"items": []}`},
+		{"```json\n{\"items\": []}\n```", `{"items": []}`},
+		{"```\n[{\"headline\": \"a\"}]\n```", `[{"headline": "a"}]`},
+		{"Here is the summary:\n{\"items\": []}\nHope this helps!", `{"items": []}`},
+		{`[{"headline": "a"}] and {more}`, `[{"headline": "a"}]`},
+		{"no json here", ""},
+		{"unclosed {", ""},
+		{"} backwards {", ""},
+	}
+	for _, tt := range tests {
+		if got := extractJSON(tt.in); got != tt.want {
+			t.Errorf("extractJSON(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSummaryItems(t *testing.T) {
+	const source = "https://example.com/blog/"
+	item := SummaryItem{Headline: "Go 1.26", Summary: "Released.", Link: "https://go.dev/doc/go1.26", Topics: []string{"go"}, Importance: 4}
+
+	tests := []struct {
+		name     string
+		response string
+		want     []SummaryItem
+	}{
+		{"as asked",
+			`{"items": [{"headline": "Go 1.26", "summary": "Released.", "link": "https://go.dev/doc/go1.26", "topics": ["go"], "importance": 4}]}`,
+			[]SummaryItem{item}},
+		{"code fence",
+			"```json\n{\"items\": [{\"headline\": \"Go 1.26\", \"summary\": \"Released.\", \"link\": \"https://go.dev/doc/go1.26\", \"topics\": [\"go\"], \"importance\": 4}]}\n```",
+			[]SummaryItem{item}},
+		{"prose around",
+			"Sure! Here you go:\n\n{\"items\": [{\"headline\": \"Go 1.26\", \"summary\": \"Released.\", \"link\": \"https://go.dev/doc/go1.26\", \"topics\": [\"go\"], \"importance\": 4}]}\n\nLet me know.",
+			[]SummaryItem{item}},
+		{"trailing commas",
+			`{"items": [{"headline": "Go 1.26", "summary": "Released.", "link": "https://go.dev/doc/go1.26", "topics": ["go",], "importance": 4,},],}`,
+			[]SummaryItem{item}},
+		{"bare array",
+			`[{"headline": "Go 1.26", "summary": "Released.", "link": "https://go.dev/doc/go1.26", "topics": ["go"], "importance": 4}]`,
+			[]SummaryItem{item}},
+		{"bare array in code fence with trailing comma",
+			"```\n[{\"headline\": \"Go 1.26\", \"summary\": \"Released.\", \"link\": \"https://go.dev/doc/go1.26\", \"topics\": [\"go\"], \"importance\": 4},]\n```",
+			[]SummaryItem{item}},
+		{"string importance",
+			`{"items": [{"headline": "a", "importance": "5"}]}`,
+			[]SummaryItem{{Headline: "a", Importance: 5}}},
+		{"float importance",
+			`{"items": [{"headline": "a", "importance": 3.6}]}`,
+			[]SummaryItem{{Headline: "a", Importance: 4}}},
+		{"string float importance",
+			`{"items": [{"headline": "a", "importance": "2.4"}]}`,
+			[]SummaryItem{{Headline: "a", Importance: 2}}},
+		{"importance out of range",
+			`{"items": [{"headline": "a", "importance": 9}, {"headline": "b", "importance": -1}]}`,
+			[]SummaryItem{{Headline: "a", Importance: 5}, {Headline: "b", Importance: 1}}},
+		{"importance not a number",
+			`{"items": [{"headline": "a", "importance": "high"}, {"headline": "b"}]}`,
+			[]SummaryItem{{Headline: "a", Importance: defaultImportance}, {Headline: "b", Importance: defaultImportance}}},
+		{"title and url",
+			`{"items": [{"title": "a", "url": "/post"}]}`,
+			[]SummaryItem{{Headline: "a", Link: "https://example.com/post", Importance: defaultImportance}}},
+		{"topics as a string",
+			`{"items": [{"headline": "a", "topics": "Go, AI, go, ,x,y,z,w"}]}`,
+			[]SummaryItem{{Headline: "a", Topics: []string{"go", "ai", "x", "y", "z"}, Importance: defaultImportance}}},
+		{"unsafe link dropped",
+			`{"items": [{"headline": "a", "link": "javascript:alert(1)"}]}`,
+			[]SummaryItem{{Headline: "a", Importance: defaultImportance}}},
+		{"summary without headline",
+			`{"items": [{"summary": "  only   a summary "}, {"topics": ["empty"]}]}`,
+			[]SummaryItem{{Headline: "only a summary", Importance: defaultImportance}}},
+	}
+	for _, tt := range tests {
+		got, err := parseSummaryItems(tt.response, source)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: items = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, response := range []string{
+		"",
+		"I could not find any stories.",
+		`{"items": []}`,
+		`{"items": [{"topics": ["go"]}]}`,
+		`{"items": [{"headline": "a"`,
+		`{"items": {"headline": "a"}}`,
+		`{"items": [{"headline": "a"} {"headline": "b"}]}`,
+	} {
+		if items, err := parseSummaryItems(response, source); err == nil {
+			t.Errorf("parseSummaryItems(%q) = %+v, want error", response, items)
+		}
+	}
+}